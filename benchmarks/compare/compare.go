@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sudachen/playground/playtool/compare"
+)
+
+var (
+	format    = flag.String("format", "md", "output format md or html")
+	output    = flag.String("o", "", "output file, stdout by default")
+	threshold = flag.Float64("threshold", compare.DefaultOptions.Threshold, "slowdown reported as regression")
+	alpha     = flag.Float64("alpha", compare.DefaultOptions.Alpha, "significance level")
+	failOnReg = flag.Bool("fail", false, "exit with code 2 if there are regressions")
+	phase     = flag.String("phase", "", "compare only one phase of phases.js like execute")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr,
+		"usage: compare [flags] base.js[,base2.js...] target.js[,target2.js...] ...\n"+
+			"  for example: compare ../vm/classic/benchmark.js ../vm/sputnik/benchmark.js\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var runs []*compare.Run
+	for _, arg := range flag.Args() {
+		r, err := compare.LoadRun(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if *phase != "" {
+			r = r.Phase(*phase)
+		}
+		runs = append(runs, r)
+	}
+
+	cmp := compare.Compare(
+		compare.Options{Threshold: *threshold, Alpha: *alpha},
+		runs[0], runs[1:]...)
+	cmp.SortBy(0)
+
+	var wr io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		wr = f
+	}
+
+	switch *format {
+	case "html":
+		cmp.WriteHTML(wr)
+	default:
+		cmp.WriteMarkdown(wr)
+	}
+
+	if *failOnReg && len(cmp.Regressions()) != 0 {
+		os.Exit(2)
+	}
+}
@@ -6,6 +6,8 @@ if .%1. == .clean. goto :CLEAN
 
 call :BENCHMARK classic 17
 call :BENCHMARK sputnik 20
+go run ..\compare\compare.go -o compare.md classic\benchmark.js sputnik\benchmark.js
+go run ..\compare\compare.go -o execute.md -phase execute classic\phases.js sputnik\phases.js
 
 goto :EOF
 
@@ -19,9 +21,13 @@ exit /B
 :CLEAN
 for /D %%i in (classic, sputnik) do (
 	call :RM %%i\benchmark.js
+	call :RM %%i\phases.js
 	call :RM %%i\benchmark.pprof
+	if exist %%i\profile rd /S /Q %%i\profile
 	call :RM %%i\benchmark.exe
 )
+call :RM compare.md
+call :RM execute.md
 goto :EOF
 
 :RM
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/sudachen/benchmark"
@@ -12,17 +15,60 @@ import (
 	_ "github.com/sudachen/playground/branch/sputnik/tests/_classic"
 )
 
+var (
+	profileDir = flag.String("profile", "", "write CPU and heap profiles of test groups into the directory, it can't be used with --pprof")
+	memStats   = flag.Bool("memstats", false, "count allocations of benchmark phases, every test is run once more")
+)
+
 var bfo = &playtool.Bfo{
 	RootDir: filepath.Join("..", "..", "..", "testdata", "classic_test", "StateTests"),
 	NewVM:   vm.NewVM,
 	Proc:    classic.StateBench,
 	Repeat:  playtool.DefaultRepeat,
+	Phases:  &playtool.Phases{},
+	Samples: &playtool.Samples{},
+}
+
+// configure is called after flags are parsed by benchmark.Run
+func configure() error {
+	bfo.Phases.MemStats = *memStats
+	if *profileDir == "" {
+		return nil
+	}
+	if f := flag.Lookup("pprof"); f != nil && f.Value.String() == "true" {
+		return fmt.Errorf("--profile can't be used with --pprof")
+	}
+	bfo.Profile = &playtool.Profile{
+		Dir:   *profileDir,
+		CPU:   true,
+		Heap:  true,
+		Group: true,
+		Top:   10,
+		Hide:  []string{"runtime\\."},
+	}
+	return nil
 }
 
 func main() {
 	t := benchmark.Run(".", func(t *benchmark.T) error {
+		if err := configure(); err != nil {
+			return err
+		}
 		classic.RunAllStateBenchmarks(bfo, t)
 		return nil
 	})
 	t.WriteJsonResult()
+	if fn := playtool.ResultFile(); fn != "" {
+		if err := bfo.Samples.Merge(fn); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if bfo.Profile != nil {
+			if err := bfo.Profile.Merge(fn); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	if err := bfo.Phases.WriteJson("phases.js"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/sudachen/benchmark"
 	"github.com/sudachen/playground/branch/ethereum/chain"
 	et "github.com/sudachen/playground/playtool/ethereum"
@@ -10,10 +13,14 @@ const BatchLength = 2000
 var opt = &chain.Options{
 	ExportQueLen: 100,
 }
+var metrics = &chain.Metrics{}
 
 func main() {
 	bm := benchmark.Run(".", func(t *benchmark.T) error {
-		return et.ChainBench(opt, BatchLength, 0, t, nil)
+		return et.ChainBench(opt, BatchLength, 0, t, nil, metrics)
 	})
 	bm.WriteJsonResult()
+	if err := metrics.WriteCSV("blocks.csv"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
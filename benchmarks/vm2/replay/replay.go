@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sudachen/misc/run"
+	classicvm "github.com/sudachen/playground/branch/classic/vm"
+	"github.com/sudachen/playground/branch/ethereum/chain"
+	ethvm "github.com/sudachen/playground/branch/ethereum/vm"
+	sputnikvm "github.com/sudachen/playground/branch/sputnik/vm"
+	"github.com/sudachen/playground/libeth"
+	et "github.com/sudachen/playground/playtool/ethereum"
+)
+
+var vms = map[string]func() libeth.VM1{
+	"ethereum": ethvm.NewVM,
+	"classic":  libeth.NewVM1(classicvm.NewVM),
+	"sputnik":  libeth.NewVM1(sputnikvm.NewVM),
+}
+
+var (
+	chainDir  = flag.String("datadir", "", "geth data directory, the default geth directory if empty")
+	identity  = flag.String("identity", "", "chain name in the data directory, mainnet by default")
+	stateDir  = flag.String("state", "replay.db", "replay state database, it's kept between runs")
+	snapshot  = flag.Uint64("snapshot", 0, "start from the state of this block if replay state is empty")
+	last      = flag.Uint64("last", 0, "the last replayed block, the last block of the chain by default")
+	batchLen  = flag.Int("batch", et.DefaultReplayBatchLen, "count of blocks between progress reports")
+	reportDir = flag.String("reports", ".", "directory for divergence reports")
+	vmName    = flag.String("vm", "ethereum", "VM executing transactions: ethereum, classic or sputnik")
+	archive   = flag.String("archive", "", "replay blocks from the archive instead of the data directory")
+	exportTo  = flag.String("export", "", "write blocks [first,last] into the archive instead of replaying, .gz is compressed")
+	first     = flag.Uint64("first", 1, "the first block written into the archive")
+	specName  = flag.String("chain", "", "chain spec: classic or JSON file with custom genesis, go-ethereum mainnet by default")
+	metrics   = flag.String("metrics", "", "write per block metrics into CSV or JSON file")
+	txMetrics = flag.String("txmetrics", "", "write per transaction metrics into CSV file")
+	verifyPow = flag.Bool("verifypow", false, "verify seals of replayed blocks by ethash")
+	synthetic = flag.Int64("synthetic", 0, "generate the synthetic chain of -last blocks by this seed instead of the data directory")
+)
+
+func export(o *chain.Options) error {
+	var src *chain.Source
+	var err error
+	if o.Synthetic != nil {
+		src, err = chain.Generate(o)
+	} else {
+		src, err = chain.OpenSource(o)
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	l := *last
+	if l == 0 {
+		l = src.Last()
+	}
+	return chain.WriteArchive(src, *exportTo, *first, l)
+}
+
+// metrics are written even if replay failed or was interrupted
+func writeMetrics(m *chain.Metrics) error {
+	if *metrics != "" {
+		write := m.WriteCSV
+		if strings.HasSuffix(*metrics, ".json") {
+			write = m.WriteJson
+		}
+		if err := write(*metrics); err != nil {
+			return err
+		}
+	}
+	if *txMetrics != "" {
+		return m.WriteTxCSV(*txMetrics)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	newVM, ok := vms[*vmName]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown VM %s\n", *vmName)
+		os.Exit(1)
+	}
+
+	var spec *chain.Spec
+	if *specName != "" {
+		var err error
+		if spec, err = chain.FindSpec(*specName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	opt := &et.ReplayOptions{
+		Chain: &chain.Options{
+			Identity:     *identity,
+			ChainDir:     *chainDir,
+			ExportQueLen: 100,
+			Archive:      *archive,
+			Spec:         spec,
+			VerifyPow:    *verifyPow,
+		},
+		StateDir:  *stateDir,
+		Snapshot:  *snapshot,
+		Last:      *last,
+		BatchLen:  *batchLen,
+		ReportDir: *reportDir,
+		NewVM:     newVM,
+	}
+	if *synthetic != 0 {
+		s := *chain.DefaultSynthetic
+		s.Seed = *synthetic
+		if *last != 0 {
+			s.Blocks = int(*last)
+		}
+		opt.Chain.Synthetic = &s
+	}
+	if *metrics != "" || *txMetrics != "" {
+		opt.Metrics = &chain.Metrics{PerTx: *txMetrics != ""}
+	}
+
+	if *exportTo != "" {
+		if err := export(opt.Chain); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err := run.WithCancelByInterruptErr(func(ctx context.Context) error {
+		return et.Replay(opt, ctx)
+	})
+	if err2 := writeMetrics(opt.Metrics); err2 != nil {
+		fmt.Fprintln(os.Stderr, err2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if _, ok := err.(*et.DivergenceError); ok {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
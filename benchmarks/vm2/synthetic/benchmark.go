@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sudachen/benchmark"
+	"github.com/sudachen/playground/branch/ethereum/chain"
+	et "github.com/sudachen/playground/playtool/ethereum"
+)
+
+const BatchLength = 100
+
+// synthetic chain does not require chain data dir, so it runs anywhere
+var opt = &chain.Options{
+	ExportQueLen: 100,
+	Synthetic:    chain.DefaultSynthetic,
+}
+
+// blocks of the synthetic chain are small, so transactions are measured too
+var metrics = &chain.Metrics{PerTx: true}
+
+func main() {
+	bm := benchmark.Run(".", func(t *benchmark.T) error {
+		return et.ChainBench(opt, BatchLength, 0, t, nil, metrics)
+	})
+	bm.WriteJsonResult()
+	if err := metrics.WriteCSV("blocks.csv"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := metrics.WriteTxCSV("txs.csv"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
@@ -138,6 +138,8 @@ type nvm struct {
 
 	blockhash func(*big.Int) common.Hash
 	rules     RuleSet
+
+	failed error // error of the message call or contract creation
 }
 
 type database struct {
@@ -155,6 +157,20 @@ func (vm *nvm) Execute(tx *common.Transaction, bi *common.BlockInfo, st common.S
 	/*resultState*/ common.State,
 	/*executionError*/ error) {
 
+	x := vm.Prepare(tx, bi, st)
+	out, usedGas, err := x.Run()
+	return out, usedGas, x.Finish(), err
+}
+
+type execution struct {
+	vm       *nvm
+	db       *state.MicroState
+	snapshot uint64
+	message  message
+	gasLimit *big.Int
+}
+
+func (vm *nvm) Prepare(tx *common.Transaction, bi *common.BlockInfo, st common.State) common.Execution {
 	db := state.NewMicroState(st)
 	snapshot := db.Snapshot()
 	message := NewMessage(etcAddress(tx.From), etcAddressOpt(tx.To), tx.Data, tx.Value, tx.GasLimit, tx.GasPrice, tx.Nonce)
@@ -174,17 +190,29 @@ func (vm *nvm) Execute(tx *common.Transaction, bi *common.BlockInfo, st common.S
 
 	vm.db = &database{db, new(big.Int)}
 	vm.Gas = new(big.Int)
+	vm.failed = nil
 	vm.evm = etcvm.New(vm)
 
-	gaspool := new(etcc.GasPool).AddGas(bi.GasLimit)
+	return &execution{vm, db, snapshot, message, bi.GasLimit}
+}
 
-	out, usedGas, err := etcc.ApplyMessage(vm, message, gaspool)
+func (x *execution) Run() ([]byte, *big.Int, error) {
+	gaspool := new(etcc.GasPool).AddGas(x.gasLimit)
+
+	out, usedGas, err := etcc.ApplyMessage(x.vm, x.message, gaspool)
 
 	if etcc.IsNonceErr(err) || etcc.IsInvalidTxErr(err) || etcc.IsGasLimitErr(err) {
-		db.Revert(snapshot)
+		x.db.Revert(x.snapshot)
+	} else if err == nil && x.vm.failed != nil {
+		// the state transition does not return errors of the execution
+		err = &common.ExecutionFailed{Err: x.vm.failed}
 	}
 
-	return out, usedGas, db.Freeze(), err
+	return out, usedGas, err
+}
+
+func (x *execution) Finish() common.State {
+	return x.db.Freeze()
 }
 
 type account struct {
@@ -363,8 +391,12 @@ func (vm *nvm) Call(caller etcvm.ContractRef, addr etc.Address, data []byte, gas
 
 		return nil, nil
 	}
+	top := vm.depth == 0
 	ret, err := etcc.Call(vm, caller, addr, data, gas, price, value)
 	vm.Gas = gas
+	if top {
+		vm.failed = err
+	}
 	return ret, err
 }
 
@@ -395,7 +427,11 @@ func (vm *nvm) Create(caller etcvm.ContractRef, data []byte, gas, price, value *
 		obj := vm.db.GetOrNew(etcAddress(crypto.CreateAddress(address, nonce)))
 		addr = obj.Address()
 	} else {
+		top := vm.depth == 0
 		ret, addr, err = etcc.Create(vm, caller, data, gas, price, value)
+		if top {
+			vm.failed = err
+		}
 	}
 	return ret, addr, err
 }
@@ -0,0 +1,411 @@
+package chain
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/sudachen/playground/libeth"
+)
+
+const archiveVersion = 2
+
+// archive file is RLP stream of the header followed by blocks with receipts,
+// it's gzipped if file name has .gz suffix
+type archiveHeader struct {
+	Version uint
+	Spec    []byte          // JSON of the chain spec without genesis
+	Alloc   []byte          // JSON of core.GenesisAlloc, the state of the parent block
+	Headers []*types.Header // preceding headers available for BLOCKHASH, the last is the parent
+}
+
+type archiveEntry struct {
+	Block    *types.Block
+	Receipts []*types.Receipt
+}
+
+// dumpAlloc converts state into genesis allocation, it requires preimages
+// of addresses and storage keys so it's possible for early blocks only
+func dumpAlloc(st *state.StateDB) (core.GenesisAlloc, error) {
+	alloc := make(core.GenesisAlloc)
+	for a, acc := range st.RawDump().Accounts {
+		balance, ok := new(big.Int).SetString(acc.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("malformed balance of account %s", a)
+		}
+		ga := core.GenesisAccount{
+			Balance: balance,
+			Nonce:   acc.Nonce,
+			Code:    common.FromHex(acc.Code),
+		}
+		if len(acc.Storage) != 0 {
+			ga.Storage = make(map[common.Hash]common.Hash)
+			for k, v := range acc.Storage {
+				var val []byte
+				if err := rlp.DecodeBytes(common.FromHex(v), &val); err != nil {
+					return nil, fmt.Errorf("malformed storage value of account %s: %v", a, err)
+				}
+				ga.Storage[common.HexToHash(k)] = common.BytesToHash(val)
+			}
+		}
+		alloc[common.HexToAddress(a)] = ga
+	}
+	return alloc, nil
+}
+
+type archiveWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+	w  io.Writer
+}
+
+func createArchive(fn string) (*archiveWriter, error) {
+	f, err := os.Create(fn)
+	if err != nil {
+		return nil, err
+	}
+	aw := &archiveWriter{f: f, w: f}
+	if strings.HasSuffix(fn, ".gz") {
+		aw.gz = gzip.NewWriter(f)
+		aw.w = aw.gz
+	}
+	return aw, nil
+}
+
+func (aw *archiveWriter) Close() error {
+	if aw.gz != nil {
+		if err := aw.gz.Close(); err != nil {
+			aw.f.Close()
+			return err
+		}
+	}
+	return aw.f.Close()
+}
+
+// WriteArchive writes blocks in range [first,last] with receipts, chain config,
+// state of the parent block and preceding headers into the archive file
+func WriteArchive(src *Source, fn string, first, last uint64) (e error) {
+	if first == 0 {
+		first = 1
+	}
+	if last < first {
+		return fmt.Errorf("empty blocks range [%d-%d]", first, last)
+	}
+
+	parent := src.Block(first - 1)
+	if parent == nil {
+		return fmt.Errorf("block %d not found", first-1)
+	}
+	st, err := src.StateAt(parent.Root())
+	if err != nil {
+		return fmt.Errorf("failed to get state of block %d: %v", first-1, err)
+	}
+	alloc, err := dumpAlloc(st)
+	if err != nil {
+		return err
+	}
+
+	hdr := &archiveHeader{Version: archiveVersion}
+	if hdr.Spec, err = json.Marshal(src.spec()); err != nil {
+		return err
+	}
+	if hdr.Alloc, err = json.Marshal(alloc); err != nil {
+		return err
+	}
+	from := uint64(0)
+	if first > 256 {
+		from = first - 256
+	}
+	for n := from; n < first; n++ {
+		b := src.Block(n)
+		if b == nil {
+			return fmt.Errorf("block %d not found", n)
+		}
+		hdr.Headers = append(hdr.Headers, b.Header())
+	}
+
+	aw, err := createArchive(fn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := aw.Close(); err != nil && e == nil {
+			e = err
+		}
+	}()
+
+	if err := rlp.Encode(aw.w, hdr); err != nil {
+		return err
+	}
+	for nr := first; nr <= last; nr++ {
+		b := src.Block(nr)
+		if b == nil {
+			return fmt.Errorf("block %d not found", nr)
+		}
+		if err := rlp.Encode(aw.w, &archiveEntry{b, src.Receipts(b)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Archive is the chain segment read from the archive file,
+// it has only state of the parent of the first block
+type Archive struct {
+	spec     *Spec
+	engine   consensus.Engine
+	db       ethdb.Database
+	cache    state.Database
+	headers  []*types.Header // preceding headers starting from block number headers[0].Number
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+	byHash   map[common.Hash]*types.Header
+}
+
+func OpenArchive(fn string) (*Archive, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(fn, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	a, err := readArchive(rlp.NewStream(r, 0))
+	if err != nil {
+		return nil, fmt.Errorf("malformed archive %s: %v", fn, err)
+	}
+	return a, nil
+}
+
+func readArchive(s *rlp.Stream) (*Archive, error) {
+	hdr := &archiveHeader{}
+	if err := s.Decode(hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported version %d", hdr.Version)
+	}
+	if len(hdr.Headers) == 0 {
+		return nil, fmt.Errorf("there is no parent block")
+	}
+
+	a := &Archive{
+		spec:     &Spec{},
+		headers:  hdr.Headers,
+		receipts: make(map[common.Hash]types.Receipts),
+		byHash:   make(map[common.Hash]*types.Header),
+	}
+	if err := json.Unmarshal(hdr.Spec, a.spec); err != nil || a.spec.Config == nil {
+		return nil, fmt.Errorf("malformed chain spec: %v", err)
+	}
+	a.engine = a.spec.engine()
+	for _, h := range a.headers {
+		a.byHash[h.Hash()] = h
+	}
+
+	for {
+		e := &archiveEntry{}
+		if err := s.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		a.blocks = append(a.blocks, e.Block)
+		a.receipts[e.Block.Hash()] = e.Receipts
+		a.byHash[e.Block.Hash()] = e.Block.Header()
+	}
+	if len(a.blocks) == 0 {
+		return nil, fmt.Errorf("there are no blocks")
+	}
+
+	var alloc core.GenesisAlloc
+	if err := json.Unmarshal(hdr.Alloc, &alloc); err != nil {
+		return nil, fmt.Errorf("malformed allocation: %v", err)
+	}
+	if err := a.makeState(alloc); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Archive) makeState(alloc core.GenesisAlloc) error {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		return err
+	}
+	a.db = db
+	a.cache = state.NewDatabase(db)
+	st, err := state.New(common.Hash{}, a.cache)
+	if err != nil {
+		return err
+	}
+	for addr, acc := range alloc {
+		st.AddBalance(addr, acc.Balance)
+		st.SetCode(addr, acc.Code)
+		st.SetNonce(addr, acc.Nonce)
+		for k, v := range acc.Storage {
+			st.SetState(addr, k, v)
+		}
+	}
+	parent := a.headers[len(a.headers)-1]
+	root, err := st.CommitTo(db, false)
+	if err != nil {
+		return err
+	}
+	if root != parent.Root {
+		return fmt.Errorf("allocation does not match state of block %v", parent.Number)
+	}
+	return nil
+}
+
+func (a *Archive) Close() {
+	a.db.Close()
+}
+
+func (a *Archive) Config() *params.ChainConfig {
+	return a.spec.Config
+}
+
+func (a *Archive) Rules() *libeth.RuleSet {
+	return a.spec.Rules
+}
+
+func (a *Archive) Engine() consensus.Engine {
+	return a.engine
+}
+
+func (a *Archive) Chain() consensus.ChainReader {
+	return a
+}
+
+// Parent returns number of the block which state is in the archive
+func (a *Archive) Parent() uint64 {
+	return a.headers[len(a.headers)-1].Number.Uint64()
+}
+
+func (a *Archive) first() uint64 {
+	return a.blocks[0].NumberU64()
+}
+
+func (a *Archive) Last() uint64 {
+	return a.blocks[len(a.blocks)-1].NumberU64()
+}
+
+func (a *Archive) Block(nr uint64) *types.Block {
+	first := a.first()
+	if nr >= first {
+		if nr-first < uint64(len(a.blocks)) {
+			return a.blocks[nr-first]
+		}
+		return nil
+	}
+	if from := a.headers[0].Number.Uint64(); nr >= from {
+		return types.NewBlockWithHeader(a.headers[nr-from])
+	}
+	return nil
+}
+
+func (a *Archive) Receipts(b *types.Block) types.Receipts {
+	return a.receipts[b.Hash()]
+}
+
+// StateAt fails for all states except the state of the parent block
+func (a *Archive) StateAt(root common.Hash) (*state.StateDB, error) {
+	return state.New(root, a.cache)
+}
+
+func (a *Archive) CopyState(root common.Hash, db ethdb.Database) error {
+	st, err := a.StateAt(root)
+	if err != nil {
+		return err
+	}
+	return copyState(st, a.db, db)
+}
+
+// Export sends blocks in range [first,last] to the channel in order, the channel
+// is closed when all blocks are sent, the failed block is sent or context is canceled
+func (a *Archive) Export(ctx context.Context, first, last uint64) chan *Block {
+	c := make(chan *Block)
+	go func() {
+		defer close(c)
+		for nr := first; nr <= last; nr++ {
+			b, err := a.prefetch(nr)
+			if err != nil {
+				b = failedBlock(nr, err)
+			}
+			select {
+			case c <- b:
+			case <-ctx.Done():
+				return
+			}
+			if b.Err != nil {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (a *Archive) prefetch(nr uint64) (*Block, error) {
+	block := a.Block(nr)
+	if block == nil || nr < a.first() {
+		return nil, fmt.Errorf("block not found")
+	}
+	if err := a.engine.VerifySeal(a, block.Header()); err != nil {
+		return nil, err
+	}
+	return newBlock(block, a.spec.Config)
+}
+
+// consensus.ChainReader is required to finalize blocks
+
+func (a *Archive) CurrentHeader() *types.Header {
+	return a.blocks[len(a.blocks)-1].Header()
+}
+
+func (a *Archive) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if h, ok := a.byHash[hash]; ok && h.Number.Uint64() == number {
+		return h
+	}
+	return nil
+}
+
+func (a *Archive) GetHeaderByNumber(number uint64) *types.Header {
+	if b := a.Block(number); b != nil {
+		return b.Header()
+	}
+	return nil
+}
+
+func (a *Archive) GetHeaderByHash(hash common.Hash) *types.Header {
+	return a.byHash[hash]
+}
+
+func (a *Archive) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if b := a.Block(number); b != nil && b.Hash() == hash {
+		return b
+	}
+	return nil
+}
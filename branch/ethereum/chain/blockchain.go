@@ -13,7 +13,6 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/node"
-	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"os"
 	"encoding/hex"
 	"crypto/rand"
@@ -29,6 +28,11 @@ type Options struct {
 	TempDbDir		string
 	CacheSize		int
 	ExportQueLen	int
+	ExportWorkers	int // count of workers prefetching blocks, NumCPU by default
+	Archive			string // blocks are read from the archive instead of ChainDir if specified
+	Spec			*Spec  // go-ethereum mainnet or config stored in the database if nil
+	Synthetic		*Synthetic // blocks are generated into the temporary database if specified
+	VerifyPow		bool   // seals of exported blocks are verified by ethash, generated blocks are not verified
 }
 
 func dataDir(dir, identity string) string {
@@ -100,6 +104,18 @@ func NewTempDb(o *Options) (ethdb.Database, error) {
 	return &tdb{db, dbdir}, nil
 }
 
+// OpenDb opens database which is kept between runs, it's created if does not exist
+func OpenDb(o *Options, dbdir string) (ethdb.Database, error) {
+	cacheSize := o.CacheSize
+	if cacheSize == 0 {
+		cacheSize = DbCacheSize
+	}
+	return ethdb.NewLDBDatabase(
+		dbdir,
+		cacheSize,
+		databaseHandles())
+}
+
 func makeChain(o *Options) (*core.BlockChain, ethdb.Database, error) {
 	var err error
 	cacheSize := o.CacheSize
@@ -117,13 +133,16 @@ func makeChain(o *Options) (*core.BlockChain, ethdb.Database, error) {
 		return nil, nil, err
 	}
 
-	config, _, err := core.SetupGenesisBlock(db, nil)
+	config, err := o.Spec.setup(db)
 	if err != nil {
 		db.Close()
 		return nil, nil, err
 	}
 
-	engine := ethash.NewFaker()
+	engine := o.Spec.engine()
+	if o.VerifyPow {
+		engine = o.Spec.powEngine(engine)
+	}
 	vmcfg := vm.Config{}
 	bc, err := core.NewBlockChain(db, config, engine, vmcfg)
 	if err != nil {
@@ -135,63 +154,57 @@ func makeChain(o *Options) (*core.BlockChain, ethdb.Database, error) {
 
 func Export(
 	o *Options, ctx context.Context, last uint64) (
-		c chan *types.Block, 	 // blocks out channel
+		c chan *Block, 	 	 // blocks out channel
 		cfg *params.ChainConfig, // chain config
 		g *types.Block,          // genesis block
 		st *state.StateDB,       // genesis state
 		e error) {
 
-	if bc, db, err := makeChain(o); err != nil {
-		e = fmt.Errorf("failed to make chain: %v", err)
+	src, err := Open(o)
+	if err != nil {
+		e = err
 		return
-	} else {
-		if last == 0 {
-			last = bc.CurrentBlock().NumberU64()
-			fmt.Fprintf(os.Stderr,"last block %v\n",last)
-		}
-
-		cfg = bc.Config()
-		g = bc.GetBlockByNumber(0)
-		if g == nil {
-			e = fmt.Errorf("failed to get genesis block")
-			return
-		}
+	}
 
-		st, err = bc.StateAt(g.Root())
-		if err != nil {
-			e = fmt.Errorf("failed to get genesis state: %v", err)
-			return
-		}
+	if last == 0 {
+		last = src.Last()
+		fmt.Fprintf(os.Stderr,"last block %v\n",last)
+	}
 
-		c = make(chan *types.Block,o.ExportQueLen)
-		go func() {
-			defer db.Close()
-			defer close(c)
+	cfg = src.Config()
+	g = src.Block(0)
+	if g == nil {
+		src.Close()
+		e = fmt.Errorf("failed to get genesis block")
+		return
+	}
 
-			for nr := uint64(1); nr <= last; nr++ {
-				if run.Interrupted(ctx) {
-					return
-				}
-				block := bc.GetBlockByNumber(nr)
-				fmt.Fprintln(os.Stderr,"get block %#v\n",block)
-				if block == nil {
-					out.Error.Printf(
-						"failed on block No %d: block not found",
-						nr)
-					return
-				}
-				c <- block
-			}
-		}()
+	st, err = src.StateAt(g.Root())
+	if err != nil {
+		src.Close()
+		e = fmt.Errorf("failed to get genesis state: %v", err)
+		return
 	}
+
+	c = make(chan *Block,o.ExportQueLen)
+	go func() {
+		defer src.Close()
+		defer close(c)
+
+		for b := range src.Export(ctx, 1, last) {
+			c <- b
+		}
+	}()
 	return
 }
 
+// Process passes batches of blocks to pf until the channel is closed,
+// blocks before the failed one are processed and then its error is returned
 func Process(
-	c chan *types.Block, batchLen int, ctx context.Context,
-	pf func(bs []*types.Block,ctx context.Context)error) error {
+	c chan *Block, batchLen int, ctx context.Context,
+	pf func(bs []*Block,ctx context.Context)error) error {
 
-	blocks := make([]*types.Block, batchLen)
+	blocks := make([]*Block, batchLen)
 
 ProcessLoop:
 	for {
@@ -200,35 +213,44 @@ ProcessLoop:
 		}
 
 		i := 0
+		var failed error
 
 	BatchingLoop:
 		for ; i < batchLen; i++ {
-			if b, ok := <- c; ok {
+			if b, ok := <- c; ok && b.Err == nil {
 				blocks[i] = b
 			} else {
+				if ok {
+					failed = b.Err
+				}
 				break BatchingLoop
 			}
 		}
 
-		if i == 0 {
-			break ProcessLoop
+		if i > 0 {
+			if err := pf(blocks[:i], ctx); err != nil {
+				return err
+			}
 		}
-
-		bs := blocks[:i]
-		if err := pf(bs, ctx); err != nil {
-			return err
+		if failed != nil {
+			return failed
+		}
+		if i < batchLen {
+			break ProcessLoop
 		}
 	}
 
 	return nil
 }
 
+// Benchmark runs pf on batches of blocks as sub-benchmarks until the channel is
+// closed, blocks before the failed one are benchmarked and then its error is returned
 func Benchmark(
-	c chan *types.Block, batchLen int,  ctx context.Context,
+	c chan *Block, batchLen int,  ctx context.Context,
 	t *benchmark.T,
-	pf func([]*types.Block,context.Context,*benchmark.T)error) error {
+	pf func([]*Block,context.Context,*benchmark.T)error) error {
 
-	blocks := make([]*types.Block, batchLen)
+	blocks := make([]*Block, batchLen)
 
 	for {
 		if run.Interrupted(ctx) {
@@ -236,11 +258,15 @@ func Benchmark(
 		}
 
 		i := 0
+		var failed error
 
 		for ; i < batchLen; i++ {
-			if b, ok := <- c; ok {
+			if b, ok := <- c; ok && b.Err == nil {
 				blocks[i] = b
 			} else {
+				if ok {
+					failed = b.Err
+				}
 				break
 			}
 		}
@@ -253,8 +279,9 @@ func Benchmark(
 				f); err != nil {
 				return err
 			}
-		} else {
-			return nil
+		}
+		if failed != nil || len(bs) < batchLen {
+			return failed
 		}
 	}
 }
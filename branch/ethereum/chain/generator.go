@@ -0,0 +1,277 @@
+package chain
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Synthetic describes the generated chain, the same seed gives the same chain,
+// workload weights are relative frequencies of transaction kinds
+type Synthetic struct {
+	Seed        int64
+	Blocks      int
+	Accounts    int // count of funded accounts, 100 by default
+	TxsPerBlock int // it's limited by block gas limit, 20 by default
+
+	Transfers int // value transfers
+	Tokens    int // transfers of ERC20 like token
+	Storage   int // writes of StorageSlots storage slots
+	Creates   int // contract creations
+	Calls     int // recursive calls of CallDepth depth
+
+	StorageSlots int // 32 by default
+	CallDepth    int // 64 by default
+}
+
+// DefaultSynthetic is the mix of all workloads
+var DefaultSynthetic = &Synthetic{
+	Seed:      1,
+	Blocks:    1000,
+	Transfers: 4,
+	Tokens:    3,
+	Storage:   1,
+	Creates:   1,
+	Calls:     1,
+}
+
+var (
+	tokenAddress   = common.HexToAddress("0x0000000000000000000000000000000000001000")
+	storageAddress = common.HexToAddress("0x0000000000000000000000000000000000002000")
+	callsAddress   = common.HexToAddress("0x0000000000000000000000000000000000003000")
+	coinbase       = common.HexToAddress("0x0000000000000000000000000000000000c0ffee")
+
+	accountBalance = new(big.Int).Mul(big.NewInt(1e18), big.NewInt(1e6))
+	tokenBalance   = big.NewInt(1e9)
+	gasPrice       = big.NewInt(1e9)
+)
+
+// tokenCode transfers token, calldata is recipient and amount,
+// balances are stored by address
+//
+//	CALLER SLOAD PUSH1 0x20 CALLDATALOAD       ; balance amount
+//	DUP1 DUP3 LT PUSH1 0x1a JUMPI              ; if balance < amount goto fail
+//	DUP1 DUP3 SUB CALLER SSTORE                ; balance[caller] = balance-amount
+//	PUSH1 0 CALLDATALOAD DUP1 SLOAD DUP3 ADD   ; to balance[to]+amount
+//	SWAP1 SSTORE STOP                          ; balance[to] += amount
+//	fail: JUMPDEST INVALID
+var tokenCode = common.FromHex("0x3354602035808210601a578082033355600035805482019055005bfe")
+
+// storageCode writes the block number into count slots starting from start,
+// calldata is start and count
+//
+//	PUSH1 0 CALLDATALOAD PUSH1 0x20 CALLDATALOAD ; start count
+//	loop: JUMPDEST DUP1 ISZERO PUSH1 0x18 JUMPI  ; if count == 0 goto end
+//	PUSH1 1 SWAP1 SUB                            ; count--
+//	NUMBER DUP2 DUP4 ADD SSTORE                  ; slot[start+count] = number
+//	PUSH1 0x06 JUMP                              ; goto loop
+//	end: JUMPDEST STOP
+var storageCode = common.FromHex("0x6000356020355b80156018576001900343818301556006565b00")
+
+// callsCode calls itself depth times, calldata is depth
+//
+//	PUSH1 0 CALLDATALOAD DUP1 ISZERO PUSH1 0x21 JUMPI ; if depth == 0 goto end
+//	PUSH1 1 SWAP1 SUB PUSH1 0 MSTORE                  ; mem[0] = depth-1
+//	PUSH1 0 PUSH1 0 PUSH1 0x20 PUSH1 0 PUSH1 0        ; out, in and value
+//	ADDRESS PUSH2 0x1000 GAS SUB CALL POP             ; call self with gas-4096
+//	end: JUMPDEST STOP
+var callsCode = common.FromHex("0x60003580156021576001900360005260006000602060006000306110005a03f1505b00")
+
+// deployCode returns init code returning the contract code
+//
+//	PUSH1 len DUP1 PUSH1 0x0b PUSH1 0 CODECOPY PUSH1 0 RETURN
+func deployCode(code []byte) []byte {
+	return append([]byte{0x60, byte(len(code)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}, code...)
+}
+
+func word(v uint64) []byte {
+	b := make([]byte, 32)
+	binary.BigEndian.PutUint64(b[24:], v)
+	return b
+}
+
+func (s *Synthetic) withDefaults() *Synthetic {
+	x := *s
+	if x.Blocks == 0 {
+		x.Blocks = DefaultSynthetic.Blocks
+	}
+	if x.Accounts == 0 {
+		x.Accounts = 100
+	}
+	if x.TxsPerBlock == 0 {
+		x.TxsPerBlock = 20
+	}
+	if x.StorageSlots == 0 {
+		x.StorageSlots = 32
+	}
+	if x.CallDepth == 0 {
+		x.CallDepth = 64
+	}
+	if x.Transfers+x.Tokens+x.Storage+x.Creates+x.Calls == 0 {
+		x.Transfers = 1
+	}
+	return &x
+}
+
+// syntheticConfig is the default config of the synthetic chain, Homestead era
+// transactions are executed by all VMs including classic ones
+var syntheticConfig = &params.ChainConfig{
+	ChainId:        big.NewInt(61),
+	HomesteadBlock: big.NewInt(0),
+	Ethash:         new(params.EthashConfig),
+}
+
+type generator struct {
+	*Synthetic
+	cfg   *params.ChainConfig
+	rnd   *rand.Rand
+	keys  []*ecdsa.PrivateKey
+	addrs []common.Address
+	err   error // the first error of block generation
+}
+
+func newGenerator(s *Synthetic, cfg *params.ChainConfig) (*generator, error) {
+	g := &generator{
+		Synthetic: s.withDefaults(),
+		cfg:       cfg,
+		rnd:       rand.New(rand.NewSource(s.Seed)),
+	}
+	for i := 0; i < g.Accounts; i++ {
+		d := crypto.Keccak256(word(uint64(g.Seed)), word(uint64(i)))
+		k, err := crypto.ToECDSA(d)
+		if err != nil {
+			return nil, err
+		}
+		g.keys = append(g.keys, k)
+		g.addrs = append(g.addrs, crypto.PubkeyToAddress(k.PublicKey))
+	}
+	return g, nil
+}
+
+func (g *generator) genesis() *core.Genesis {
+	alloc := core.GenesisAlloc{
+		tokenAddress:   {Code: tokenCode, Balance: new(big.Int), Storage: make(map[common.Hash]common.Hash)},
+		storageAddress: {Code: storageCode, Balance: new(big.Int)},
+		callsAddress:   {Code: callsCode, Balance: new(big.Int)},
+	}
+	for _, a := range g.addrs {
+		alloc[a] = core.GenesisAccount{Balance: accountBalance}
+		alloc[tokenAddress].Storage[a.Hash()] = common.BigToHash(tokenBalance)
+	}
+	return &core.Genesis{
+		Config:     g.cfg,
+		Nonce:      uint64(g.Seed),
+		GasLimit:   params.GenesisGasLimit * 4,
+		Difficulty: params.MinimumDifficulty,
+		Alloc:      alloc,
+	}
+}
+
+// tx makes random transaction of random account
+func (g *generator) tx(b *core.BlockGen) (*types.Transaction, error) {
+	i := g.rnd.Intn(len(g.keys))
+	from := g.addrs[i]
+	to := g.addrs[g.rnd.Intn(len(g.addrs))]
+	nonce := b.TxNonce(from)
+
+	var tx *types.Transaction
+	n := g.rnd.Intn(g.Transfers + g.Tokens + g.Storage + g.Creates + g.Calls)
+	switch {
+	case n < g.Transfers:
+		value := big.NewInt(g.rnd.Int63n(1e18) + 1)
+		tx = types.NewTransaction(nonce, to, value, params.TxGas, gasPrice, nil)
+	case n < g.Transfers+g.Tokens:
+		data := append(common.LeftPadBytes(to.Bytes(), 32), word(uint64(g.rnd.Intn(100)+1))...)
+		tx = types.NewTransaction(nonce, tokenAddress, new(big.Int), 100000, gasPrice, data)
+	case n < g.Transfers+g.Tokens+g.Storage:
+		data := append(word(uint64(g.rnd.Intn(1<<16))), word(uint64(g.StorageSlots))...)
+		gas := uint64(g.StorageSlots)*(params.SstoreSetGas+100) + 50000
+		tx = types.NewTransaction(nonce, storageAddress, new(big.Int), gas, gasPrice, data)
+	case n < g.Transfers+g.Tokens+g.Storage+g.Creates:
+		tx = types.NewContractCreation(nonce, new(big.Int), 200000, gasPrice, deployCode(tokenCode))
+	default:
+		gas := uint64(g.CallDepth)*5000 + 100000
+		tx = types.NewTransaction(nonce, callsAddress, new(big.Int), gas, gasPrice, word(uint64(g.CallDepth)))
+	}
+
+	return types.SignTx(tx, types.MakeSigner(g.cfg, b.Number()), g.keys[i])
+}
+
+// block fills the block by transactions, core.GenerateChain does not
+// accept errors, so the error is kept and next blocks are left empty
+func (g *generator) block(i int, b *core.BlockGen) {
+	b.SetCoinbase(coinbase)
+	if g.err != nil {
+		return
+	}
+	limit := core.CalcGasLimit(b.PrevBlock(-1))
+	used := uint64(0)
+	for k := 0; k < g.TxsPerBlock; k++ {
+		tx, err := g.tx(b)
+		if err != nil {
+			g.err = fmt.Errorf("failed to sign transaction of block %d: %v", b.Number(), err)
+			return
+		}
+		if used+tx.Gas() > limit {
+			break
+		}
+		used += tx.Gas()
+		b.AddTx(tx)
+	}
+}
+
+// Generate builds the synthetic chain in the temporary database,
+// chain config is taken from Options.Spec or it's Homestead since genesis
+func Generate(o *Options) (*Source, error) {
+	cfg := syntheticConfig
+	if o.Spec != nil {
+		cfg = o.Spec.config()
+	}
+
+	g, err := newGenerator(o.Synthetic, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	to := *o
+	to.TempDbDir = ""
+	db, err := NewTempDb(&to)
+	if err != nil {
+		return nil, err
+	}
+
+	genesis, err := g.genesis().Commit(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	engine := o.Spec.engine()
+	blocks, _ := core.GenerateChain(cfg, genesis, engine, db, g.Blocks, g.block)
+	if g.err != nil {
+		db.Close()
+		return nil, g.err
+	}
+
+	bc, err := core.NewBlockChain(db, cfg, engine, vm.Config{})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if n, err := bc.InsertChain(blocks); err != nil {
+		bc.Stop()
+		db.Close()
+		return nil, fmt.Errorf("failed to insert generated block %d: %v", n, err)
+	}
+
+	return &Source{o, bc, db}, nil
+}
@@ -0,0 +1,36 @@
+package chain
+
+import (
+	"testing"
+)
+
+func generated(t *testing.T, seed int64) *Source {
+	src, err := Generate(&Options{Synthetic: &Synthetic{Seed: seed, Blocks: 8, TxsPerBlock: 5, Transfers: 1, Tokens: 1, Storage: 1, Creates: 1, Calls: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	a, b, c := generated(t, 1), generated(t, 1), generated(t, 2)
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	if a.Last() != 8 {
+		t.Fatalf("wrong count of blocks %d", a.Last())
+	}
+	if len(a.Block(8).Transactions()) == 0 {
+		t.Error("block has no transactions")
+	}
+	if a.Block(8).Hash() != b.Block(8).Hash() {
+		t.Error("the same seed gives different chains")
+	}
+	if a.Block(8).Hash() == c.Block(8).Hash() {
+		t.Error("different seeds give the same chain")
+	}
+	if a.Config().IsEIP155(a.Block(8).Number()) {
+		t.Error("synthetic chain is not Homestead")
+	}
+}
@@ -0,0 +1,191 @@
+package chain
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxMetrics is the execution of the transaction, To is nil for contract creation
+type TxMetrics struct {
+	Index     int             `json:"index"`
+	Hash      common.Hash     `json:"hash"`
+	To        *common.Address `json:"to"`
+	GasUsed   uint64          `json:"gasUsed"`
+	Execution time.Duration   `json:"execution"`
+}
+
+// BlockMetrics is the replay of the block, Execution includes transactions
+// and intermediate roots, Commit includes finalization and state commit,
+// diverged block has metrics of its replay until divergence is found
+type BlockMetrics struct {
+	Number    uint64        `json:"number"`
+	Hash      common.Hash   `json:"hash"`
+	Txs       int           `json:"txs"`
+	GasUsed   uint64        `json:"gasUsed"`
+	Execution time.Duration `json:"execution"`
+	Commit    time.Duration `json:"commit"`
+	Mgas      float64       `json:"mgasPerSec"` // gas per execution time
+	Diverged  bool          `json:"diverged,omitempty"`
+	TxMetrics []*TxMetrics  `json:"transactions,omitempty"`
+
+	m *Metrics
+}
+
+// Metrics collects the time series of replayed blocks, so it can be seen
+// which blocks or contracts dominate execution cost
+type Metrics struct {
+	PerTx bool // measure every transaction
+
+	mu     sync.Mutex
+	blocks []*BlockMetrics
+}
+
+// Block starts metrics of the block, it returns nil if m is nil,
+// metrics are added when Done is called
+func (m *Metrics) Block(b *Block) *BlockMetrics {
+	if m == nil {
+		return nil
+	}
+	return &BlockMetrics{
+		Number: b.NumberU64(),
+		Hash:   b.Hash(),
+		Txs:    len(b.Transactions()),
+		m:      m,
+	}
+}
+
+// Now returns the current time if metrics of the block are collected,
+// so time is not taken when they are disabled, it's safe to call it on nil
+func (bm *BlockMetrics) Now() time.Time {
+	if bm == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// TxNow is Now for metrics of transactions
+func (bm *BlockMetrics) TxNow() time.Time {
+	if bm == nil || !bm.m.PerTx {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// Tx adds metrics of the transaction executed since started if per transaction
+// metrics are enabled, it's safe to call it on nil
+func (bm *BlockMetrics) Tx(i int, tx *types.Transaction, gas uint64, started time.Time) {
+	if bm == nil || !bm.m.PerTx {
+		return
+	}
+	bm.TxMetrics = append(bm.TxMetrics, &TxMetrics{
+		Index:     i,
+		Hash:      tx.Hash(),
+		To:        tx.To(),
+		GasUsed:   gas,
+		Execution: time.Since(started),
+	})
+}
+
+// Diverge marks the block as diverged, Done still has to be called,
+// it's safe to call it on nil
+func (bm *BlockMetrics) Diverge() {
+	if bm != nil {
+		bm.Diverged = true
+	}
+}
+
+// Done completes metrics of the block executed since started
+// and committed since executed, it's safe to call it on nil
+func (bm *BlockMetrics) Done(gas uint64, started, executed time.Time) {
+	if bm == nil {
+		return
+	}
+	execution := executed.Sub(started)
+	bm.GasUsed = gas
+	bm.Execution = execution
+	bm.Commit = time.Since(executed)
+	if execution > 0 {
+		bm.Mgas = float64(gas) / execution.Seconds() / 1e6
+	}
+	bm.m.mu.Lock()
+	bm.m.blocks = append(bm.m.blocks, bm)
+	bm.m.mu.Unlock()
+}
+
+func (m *Metrics) Blocks() []*BlockMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*BlockMetrics(nil), m.blocks...)
+}
+
+func (m *Metrics) WriteJson(fn string) error {
+	b, err := json.MarshalIndent(m.Blocks(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, b, 0644)
+}
+
+func writeCSV(fn string, rows [][]string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func u64(v uint64) string       { return strconv.FormatUint(v, 10) }
+func ns(d time.Duration) string { return strconv.FormatInt(int64(d), 10) }
+
+// WriteCSV writes a row per block, times are in nanoseconds
+func (m *Metrics) WriteCSV(fn string) error {
+	rows := [][]string{{"number", "hash", "txs", "gas", "execution", "commit", "mgas", "diverged"}}
+	for _, bm := range m.Blocks() {
+		rows = append(rows, []string{
+			u64(bm.Number),
+			bm.Hash.Hex(),
+			strconv.Itoa(bm.Txs),
+			u64(bm.GasUsed),
+			ns(bm.Execution),
+			ns(bm.Commit),
+			strconv.FormatFloat(bm.Mgas, 'f', 3, 64),
+			strconv.FormatBool(bm.Diverged),
+		})
+	}
+	return writeCSV(fn, rows)
+}
+
+// WriteTxCSV writes a row per transaction, the recipient is empty for contract creation
+func (m *Metrics) WriteTxCSV(fn string) error {
+	rows := [][]string{{"number", "index", "hash", "to", "gas", "execution"}}
+	for _, bm := range m.Blocks() {
+		for _, tm := range bm.TxMetrics {
+			to := ""
+			if tm.To != nil {
+				to = tm.To.Hex()
+			}
+			rows = append(rows, []string{
+				u64(bm.Number),
+				strconv.Itoa(tm.Index),
+				tm.Hash.Hex(),
+				to,
+				u64(tm.GasUsed),
+				ns(tm.Execution),
+			})
+		}
+	}
+	return writeCSV(fn, rows)
+}
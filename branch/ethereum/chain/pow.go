@@ -0,0 +1,16 @@
+package chain
+
+import (
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/sudachen/playground/ethash"
+)
+
+// powEngine wraps the engine of the chain, so seals are verified
+// by the playground ethash instead of being accepted by the faker
+func (s *Spec) powEngine(engine consensus.Engine) consensus.Engine {
+	light := &ethash.Light{}
+	if s != nil {
+		light.ECIP1099Block = s.ECIP1099
+	}
+	return ethash.NewEngine(engine, light)
+}
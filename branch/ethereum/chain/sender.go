@@ -0,0 +1,68 @@
+package chain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/sudachen/playground/crypto"
+)
+
+var big8 = big.NewInt(8)
+
+// txSignature checks the signature of the transaction by rules of the signer
+// types.MakeSigner returns for the block, as types.Sender does, and converts it
+// to the [R || S || V] format to be recovered by crypto.RecoverBatch
+func txSignature(tx *types.Transaction, cfg *params.ChainConfig, number *big.Int) (crypto.Signature, error) {
+	signer := types.MakeSigner(cfg, number)
+	homestead := cfg.IsHomestead(number)
+	v, r, s := tx.RawSignatureValues()
+	V := new(big.Int).Set(v)
+	if cfg.IsEIP155(number) {
+		homestead = true
+		if tx.Protected() {
+			if tx.ChainId().Cmp(cfg.ChainId) != 0 {
+				return crypto.Signature{}, types.ErrInvalidChainId
+			}
+			V.Sub(V, new(big.Int).Mul(cfg.ChainId, big.NewInt(2)))
+			V.Sub(V, big8)
+		} else {
+			// not protected transaction is signed without the chain id
+			signer = types.HomesteadSigner{}
+		}
+	}
+	if V.BitLen() > 8 || !crypto.ValidateSignatureValues(byte(V.Uint64()), r, s, homestead) {
+		return crypto.Signature{}, types.ErrInvalidSig
+	}
+	sig := make([]byte, 65)
+	copy(sig[32-len(r.Bytes()):32], r.Bytes())
+	copy(sig[64-len(s.Bytes()):64], s.Bytes())
+	sig[64] = byte(V.Uint64() - 27)
+	hash := signer.Hash(tx)
+	return crypto.Signature{Key: tx.Hash(), Hash: hash[:], Sig: sig}, nil
+}
+
+// recoverMessages recovers senders of all transactions of the block in parallel,
+// senders of already replayed transactions are taken from the cache. Workers of
+// crypto.DefaultRecoverer are shared by blocks prefetched at the same time.
+func recoverMessages(block *types.Block, cfg *params.ChainConfig) ([]types.Message, error) {
+	txs := block.Transactions()
+	sigs := make([]crypto.Signature, len(txs))
+	for i, tx := range txs {
+		sig, err := txSignature(tx, cfg, block.Number())
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover sender of tx %d: %v", i, err)
+		}
+		sigs[i] = sig
+	}
+	msgs := make([]types.Message, len(txs))
+	for i, r := range crypto.RecoverBatch(sigs) {
+		if r.Err != nil {
+			return nil, fmt.Errorf("failed to recover sender of tx %d: %v", i, r.Err)
+		}
+		tx := txs[i]
+		msgs[i] = types.NewMessage(r.Address, tx.To(), tx.Nonce(), tx.Value(), tx.Gas(), tx.GasPrice(), tx.Data(), true)
+	}
+	return msgs, nil
+}
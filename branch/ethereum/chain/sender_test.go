@@ -0,0 +1,60 @@
+package chain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sudachen/playground/crypto"
+)
+
+func TestTxSignature(t *testing.T) {
+	key, _ := crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	cfg := classicConfig
+
+	for _, c := range []struct {
+		number uint64
+		signer types.Signer
+	}{
+		{1, types.FrontierSigner{}},
+		{1150000, types.HomesteadSigner{}},
+		{3000000, types.HomesteadSigner{}},
+		{3000000, types.NewEIP155Signer(cfg.ChainId)},
+	} {
+		number := new(big.Int).SetUint64(c.number)
+		tx, err := types.SignTx(types.NewTransaction(c.number, to, big.NewInt(1), 21000, big.NewInt(1), nil), c.signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := types.Sender(types.MakeSigner(cfg, number), tx)
+		if err != nil || expected != from {
+			t.Fatalf("block %d: go-ethereum sender %x %v", c.number, expected, err)
+		}
+		sig, err := txSignature(tx, cfg, number)
+		if err != nil {
+			t.Fatalf("block %d protected %v: %v", c.number, tx.Protected(), err)
+		}
+		if r := crypto.RecoverBatch([]crypto.Signature{sig})[0]; r.Err != nil || r.Address != expected {
+			t.Errorf("block %d protected %v: sender %x %v", c.number, tx.Protected(), r.Address, r.Err)
+		}
+
+		block := types.NewBlock(&types.Header{Number: number}, []*types.Transaction{tx}, nil, nil)
+		msgs, err := recoverMessages(block, cfg)
+		if err != nil || msgs[0].From() != expected {
+			t.Errorf("block %d protected %v: message sender %v", c.number, tx.Protected(), err)
+		}
+	}
+
+	// signature of Morden is not valid on Ethereum Classic, protected
+	// transaction is not valid before EIP-155
+	tx, _ := types.SignTx(types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), nil), types.NewEIP155Signer(big.NewInt(62)), key)
+	for _, number := range []*big.Int{big.NewInt(3000000), big.NewInt(1150000)} {
+		_, gethErr := types.Sender(types.MakeSigner(cfg, number), tx)
+		if _, err := txSignature(tx, cfg, number); err == nil || gethErr == nil {
+			t.Errorf("block %d: signature of other chain is accepted: %v, go-ethereum %v", number, err, gethErr)
+		}
+	}
+}
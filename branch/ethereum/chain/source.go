@@ -0,0 +1,271 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/sudachen/misc/run"
+	"github.com/sudachen/playground/libeth"
+)
+
+// Reader is the source of replayed blocks, the chain data directory or the archive
+type Reader interface {
+	Config() *params.ChainConfig
+	Rules() *libeth.RuleSet
+	Engine() consensus.Engine
+	Chain() consensus.ChainReader
+	Last() uint64
+	Block(nr uint64) *types.Block
+	Receipts(b *types.Block) types.Receipts
+	StateAt(root common.Hash) (*state.StateDB, error)
+	CopyState(root common.Hash, db ethdb.Database) error
+	Export(ctx context.Context, first, last uint64) chan *Block
+	Close()
+}
+
+// Open opens the archive if Options.Archive is specified,
+// otherwise it opens the chain data directory
+func Open(o *Options) (Reader, error) {
+	if o.Archive != "" {
+		a, err := OpenArchive(o.Archive)
+		if err != nil {
+			return nil, err
+		}
+		if o.VerifyPow {
+			a.engine = a.spec.powEngine(a.engine)
+		}
+		return a, nil
+	}
+	if o.Synthetic != nil {
+		return Generate(o)
+	}
+	return OpenSource(o)
+}
+
+// Source is the opened chain data directory, blocks, receipts and states
+// are read from it while it's not closed
+type Source struct {
+	o  *Options
+	bc *core.BlockChain
+	db ethdb.Database
+}
+
+func OpenSource(o *Options) (*Source, error) {
+	bc, db, err := makeChain(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make chain: %v", err)
+	}
+	return &Source{o, bc, db}, nil
+}
+
+func (s *Source) Close() {
+	s.bc.Stop()
+	s.db.Close()
+}
+
+func (s *Source) Config() *params.ChainConfig {
+	return s.bc.Config()
+}
+
+func (s *Source) Rules() *libeth.RuleSet {
+	return s.o.Spec.RuleSet()
+}
+
+// spec returns spec of the chain without genesis
+func (s *Source) spec() *Spec {
+	spec := &Spec{Name: "mainnet", Config: s.Config()}
+	if s.o.Synthetic != nil {
+		spec.Name = "synthetic"
+	}
+	if s.o.Spec != nil {
+		x := *s.o.Spec
+		x.Config = spec.Config
+		x.Genesis = nil
+		spec = &x
+	}
+	return spec
+}
+
+func (s *Source) Engine() consensus.Engine {
+	return s.bc.Engine()
+}
+
+// Chain is used by the consensus engine to finalize replayed blocks
+func (s *Source) Chain() consensus.ChainReader {
+	return s.bc
+}
+
+func (s *Source) Last() uint64 {
+	return s.bc.CurrentBlock().NumberU64()
+}
+
+func (s *Source) Block(nr uint64) *types.Block {
+	return s.bc.GetBlockByNumber(nr)
+}
+
+// Receipts returns nil if the chain does not have receipts of the block
+func (s *Source) Receipts(b *types.Block) types.Receipts {
+	return core.GetBlockReceipts(s.db, b.Hash(), b.NumberU64())
+}
+
+// StateAt fails if the chain does not have state of the block,
+// usually only recent states are kept by not archive nodes
+func (s *Source) StateAt(root common.Hash) (*state.StateDB, error) {
+	return s.bc.StateAt(root)
+}
+
+// CopyState copies state trie nodes, storages and contracts code
+// of the state into the database
+func (s *Source) CopyState(root common.Hash, db ethdb.Database) error {
+	st, err := s.StateAt(root)
+	if err != nil {
+		return err
+	}
+	return copyState(st, s.db, db)
+}
+
+func copyState(st *state.StateDB, from, to ethdb.Database) error {
+	it := state.NewNodeIterator(st)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			// embedded node
+			continue
+		}
+		b, err := from.Get(it.Hash.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to copy state node %v: %v", it.Hash.Hex(), err)
+		}
+		if err := to.Put(it.Hash.Bytes(), b); err != nil {
+			return err
+		}
+	}
+	return it.Error
+}
+
+// Block is the exported block with messages of its transactions,
+// the failed block has only the error and it's the last one exported
+type Block struct {
+	*types.Block
+	Messages []types.Message
+	Err      error
+}
+
+func failedBlock(nr uint64, err error) *Block {
+	return &Block{Err: fmt.Errorf("failed on block No %d: %v", nr, err)}
+}
+
+type exportJob struct {
+	nr   uint64
+	done chan *Block
+}
+
+// prefetch reads block, verifies its seal and recovers senders of its transactions,
+// the seal is accepted by the faker engine unless Options.VerifyPow is set
+func (s *Source) prefetch(nr uint64) (*Block, error) {
+	block := s.bc.GetBlockByNumber(nr)
+	if block == nil {
+		return nil, fmt.Errorf("block not found")
+	}
+	if err := s.Engine().VerifySeal(s.bc, block.Header()); err != nil {
+		return nil, err
+	}
+	return newBlock(block, s.Config())
+}
+
+func newBlock(block *types.Block, cfg *params.ChainConfig) (*Block, error) {
+	msgs, err := recoverMessages(block, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Block{Block: block, Messages: msgs}, nil
+}
+
+func (s *Source) workers() int {
+	if s.o.ExportWorkers > 0 {
+		return s.o.ExportWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// Export sends blocks in range [first,last] to the channel in order,
+// blocks are prefetched, seals are verified and senders are recovered by pool of workers,
+// the channel is closed when all blocks are sent, the failed block is sent or context is canceled
+func (s *Source) Export(ctx context.Context, first, last uint64) chan *Block {
+	ctx, cancel := context.WithCancel(ctx)
+	workers := s.workers()
+	queLen := s.o.ExportQueLen
+	if queLen < workers {
+		queLen = workers
+	}
+
+	c := make(chan *Block, s.o.ExportQueLen)
+	jobs := make(chan *exportJob, workers)
+	order := make(chan *exportJob, queLen)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				b, err := s.prefetch(j.nr)
+				if err != nil {
+					b = failedBlock(j.nr, err)
+				}
+				j.done <- b
+			}
+		}()
+	}
+
+	go func() {
+		defer close(order)
+		defer close(jobs)
+
+		for nr := first; nr <= last; nr++ {
+			// job is queued before it's ordered, so every ordered job is done
+			j := &exportJob{nr, make(chan *Block, 1)}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case order <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(c)
+		defer wg.Wait()
+		defer cancel()
+
+		for j := range order {
+			b := <-j.done
+			if run.Interrupted(ctx) {
+				return
+			}
+			select {
+			case c <- b:
+			case <-ctx.Done():
+				return
+			}
+			if b.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return c
+}
@@ -0,0 +1,293 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/sudachen/playground/libeth"
+)
+
+// Spec describes the chain, nil spec means go-ethereum mainnet or
+// the chain which config is stored in the database
+type Spec struct {
+	Name        string              `json:"name"`
+	Config      *params.ChainConfig `json:"config,omitempty"`      // config of the genesis is used if nil
+	Genesis     *core.Genesis       `json:"genesis,omitempty"`     // committed into the empty database
+	GenesisHash common.Hash         `json:"genesisHash,omitempty"` // checked if not zero
+	Rules       *libeth.RuleSet     `json:"rules,omitempty"`       // fork schedule of the classic VMs
+	ECIP1017    bool                `json:"ecip1017,omitempty"`    // ETC monetary policy
+	ECIP1017Era uint64              `json:"ecip1017Era,omitempty"` // blocks in the era of ECIP-1017, 5M by default
+	ECIP1099    uint64              `json:"ecip1099,omitempty"`    // block since which ethash epoch length is doubled
+}
+
+// ClassicMainnet is Ethereum Classic, it has the same genesis as Ethereum
+// but does not have DAO fork and has its own fork schedule and block rewards
+var ClassicMainnet = &Spec{
+	Name:        "classic",
+	Config:      classicConfig,
+	Genesis:     classicGenesis(),
+	GenesisHash: params.MainnetGenesisHash,
+	Rules: &libeth.RuleSet{
+		HomesteadBlock:           big.NewInt(1150000),
+		DAOForkBlock:             big.NewInt(1920000),
+		HomesteadGasRepriceBlock: big.NewInt(2500000),
+		DiehardBlock:             big.NewInt(3000000),
+		ExplosionBlock:           big.NewInt(5000000),
+		ChainId:                  libeth.ClassicChainId,
+	},
+	ECIP1017: true,
+	ECIP1099: 11700000,
+}
+
+// go-ethereum config can't enable EIP-160 without EIP-161 and EIP-170,
+// so EIP-160 of Diehard is applied by the classic VMs only, and go-ethereum VM
+// gets all three since Atlantis as Byzantium, Agharta is Constantinople
+var classicConfig = &params.ChainConfig{
+	ChainId:             big.NewInt(61),
+	HomesteadBlock:      big.NewInt(1150000),
+	DAOForkBlock:        big.NewInt(1920000),
+	DAOForkSupport:      false,
+	EIP150Block:         big.NewInt(2500000),
+	EIP155Block:         big.NewInt(3000000),
+	EIP158Block:         big.NewInt(8772000),
+	ByzantiumBlock:      big.NewInt(8772000),
+	ConstantinopleBlock: big.NewInt(9573000),
+	Ethash:              new(params.EthashConfig),
+}
+
+func classicGenesis() *core.Genesis {
+	g := core.DefaultGenesisBlock()
+	g.Config = classicConfig
+	return g
+}
+
+// ClassicMorden is the old Ethereum Classic testnet, its accounts start
+// with nonce 2^20, go-ethereum state does not support it,
+// so only blocks without new senders and contracts are replayed exactly
+var ClassicMorden = &Spec{
+	Name:        "morden",
+	Config:      mordenConfig,
+	Genesis:     mordenGenesis(),
+	GenesisHash: common.HexToHash("0x0cd786a2425d16f152c658316c423e6ce1181e15c3295826d7c9904cba9ce303"),
+	Rules: &libeth.RuleSet{
+		HomesteadBlock:           big.NewInt(494000),
+		DAOForkBlock:             big.NewInt(1885000),
+		HomesteadGasRepriceBlock: big.NewInt(1783000),
+		DiehardBlock:             big.NewInt(1915000),
+		ExplosionBlock:           big.NewInt(2000000),
+		ChainId:                  libeth.MordenChainId,
+	},
+	ECIP1017:    true,
+	ECIP1017Era: 2000000,
+}
+
+var mordenConfig = &params.ChainConfig{
+	ChainId:             big.NewInt(62),
+	HomesteadBlock:      big.NewInt(494000),
+	DAOForkBlock:        big.NewInt(1885000),
+	DAOForkSupport:      false,
+	EIP150Block:         big.NewInt(1783000),
+	EIP155Block:         big.NewInt(1915000),
+	EIP158Block:         big.NewInt(4729274),
+	ByzantiumBlock:      big.NewInt(4729274),
+	ConstantinopleBlock: big.NewInt(5000381),
+	Ethash:              new(params.EthashConfig),
+}
+
+func mordenGenesis() *core.Genesis {
+	nonce := uint64(1 << 20)
+	alloc := core.GenesisAlloc{
+		common.HexToAddress("0x102e61f5d8f9bc71d0ad4a084df4e65e05ce0e1c"): {
+			Balance: new(big.Int).Exp(big.NewInt(2), big.NewInt(200), nil),
+			Nonce:   nonce,
+		},
+	}
+	for i := int64(1); i <= 4; i++ {
+		alloc[common.BigToAddress(big.NewInt(i))] = core.GenesisAccount{Balance: big.NewInt(1), Nonce: nonce}
+	}
+	return &core.Genesis{
+		Config:     mordenConfig,
+		Nonce:      0x6d6f7264656e,
+		GasLimit:   0x2fefd8,
+		Difficulty: big.NewInt(0x20000),
+		Mixhash:    common.HexToHash("0x00000000000000000000000000000000000000647572616c65787365646c6578"),
+		Alloc:      alloc,
+	}
+}
+
+// ClassicMordor is Ethereum Classic testnet started with Atlantis rules
+var ClassicMordor = &Spec{
+	Name:        "mordor",
+	Config:      mordorConfig,
+	Genesis:     mordorGenesis(),
+	GenesisHash: common.HexToHash("0xa68ebde7932eccb177d38d55dcc6461a019dd795a681e59b5a3e4f3a7259a3f1"),
+	ECIP1017:    true,
+	ECIP1017Era: 2000000,
+	ECIP1099:    2520000,
+}
+
+var mordorConfig = &params.ChainConfig{
+	ChainId:             big.NewInt(63),
+	HomesteadBlock:      big.NewInt(0),
+	EIP150Block:         big.NewInt(0),
+	EIP155Block:         big.NewInt(0),
+	EIP158Block:         big.NewInt(0),
+	ByzantiumBlock:      big.NewInt(0),
+	ConstantinopleBlock: big.NewInt(301243),
+	Ethash:              new(params.EthashConfig),
+}
+
+func mordorGenesis() *core.Genesis {
+	return &core.Genesis{
+		Config:     mordorConfig,
+		Timestamp:  0x5d9676db,
+		ExtraData:  []byte("phoenix chicken absurd banana"),
+		GasLimit:   0x2fefd8,
+		Difficulty: big.NewInt(0x20000),
+		Alloc:      core.GenesisAlloc{},
+	}
+}
+
+// Specs are known chains, other chains are loaded by LoadSpec
+var Specs = map[string]*Spec{
+	"classic": ClassicMainnet,
+	"morden":  ClassicMorden,
+	"mordor":  ClassicMordor,
+}
+
+// LoadSpec reads chain spec from JSON file, genesis has the same format as geth init uses
+func LoadSpec(fn string) (*Spec, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	if err := json.Unmarshal(b, spec); err != nil {
+		return nil, fmt.Errorf("malformed chain spec %s: %v", fn, err)
+	}
+	if spec.Config == nil && (spec.Genesis == nil || spec.Genesis.Config == nil) {
+		return nil, fmt.Errorf("chain spec %s does not have config", fn)
+	}
+	return spec, nil
+}
+
+// FindSpec returns known spec by name or loads it from file
+func FindSpec(name string) (*Spec, error) {
+	if s, ok := Specs[name]; ok {
+		return s, nil
+	}
+	return LoadSpec(name)
+}
+
+// setup returns config of the chain, genesis is committed if database is empty
+func (s *Spec) setup(db ethdb.Database) (*params.ChainConfig, error) {
+	if s == nil {
+		cfg, _, err := core.SetupGenesisBlock(db, nil)
+		return cfg, err
+	}
+
+	stored := core.GetCanonicalHash(db, 0)
+	if stored == (common.Hash{}) {
+		if s.Genesis == nil {
+			return nil, fmt.Errorf("database is empty and %s spec does not have genesis", s.Name)
+		}
+		// specs are shared, so the genesis is copied to set its config
+		genesis := *s.Genesis
+		if genesis.Config == nil {
+			genesis.Config = s.Config
+		}
+		b, err := genesis.Commit(db)
+		if err != nil {
+			return nil, err
+		}
+		stored = b.Hash()
+	}
+	if s.GenesisHash != (common.Hash{}) && stored != s.GenesisHash {
+		return nil, fmt.Errorf("genesis %v does not match %s chain", stored.Hex(), s.Name)
+	}
+
+	return s.config(), nil
+}
+
+func (s *Spec) config() *params.ChainConfig {
+	if s.Config != nil {
+		return s.Config
+	}
+	return s.Genesis.Config
+}
+
+// RuleSet returns fork schedule of the classic VMs, nil means it's mapped
+// from the chain config by libeth.ConfigRules
+func (s *Spec) RuleSet() *libeth.RuleSet {
+	if s == nil {
+		return nil
+	}
+	return s.Rules
+}
+
+func (s *Spec) engine() consensus.Engine {
+	if s != nil && s.ECIP1017 {
+		era := s.ECIP1017Era
+		if era == 0 {
+			era = ecip1017EraLength
+		}
+		return &classicEngine{ethash.NewFaker(), new(big.Int).SetUint64(era)}
+	}
+	return ethash.NewFaker()
+}
+
+const ecip1017EraLength = 5000000
+
+var blockReward = big.NewInt(5e+18)
+
+// classicEngine finalizes blocks with ECIP-1017 rewards,
+// block reward is reduced by 20% every era of 5M blocks on mainnet and
+// uncles get 1/32 of the block reward since the second era
+type classicEngine struct {
+	consensus.Engine
+	era *big.Int
+}
+
+func (e *classicEngine) Finalize(
+	chain consensus.ChainReader, header *types.Header, st *state.StateDB,
+	txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+
+	accumulateClassicRewards(st, header, uncles, e.era)
+	header.Root = st.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	return types.NewBlock(header, txs, uncles, receipts), nil
+}
+
+func accumulateClassicRewards(st *state.StateDB, header *types.Header, uncles []*types.Header, eraLength *big.Int) {
+	era := new(big.Int).Sub(header.Number, big.NewInt(1))
+	era.Div(era, eraLength)
+
+	reward := new(big.Int).Set(blockReward)
+	reward.Mul(reward, new(big.Int).Exp(big.NewInt(4), era, nil))
+	reward.Div(reward, new(big.Int).Exp(big.NewInt(5), era, nil))
+
+	minerReward := new(big.Int).Set(reward)
+	for _, uncle := range uncles {
+		r := new(big.Int)
+		if era.Sign() == 0 {
+			r.Add(uncle.Number, big.NewInt(8))
+			r.Sub(r, header.Number)
+			r.Mul(r, reward)
+			r.Div(r, big.NewInt(8))
+		} else {
+			r.Div(reward, big.NewInt(32))
+		}
+		st.AddBalance(uncle.Coinbase, r)
+		minerReward.Add(minerReward, new(big.Int).Div(reward, big.NewInt(32)))
+	}
+	st.AddBalance(header.Coinbase, minerReward)
+}
@@ -0,0 +1,37 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func TestSpecGenesis(t *testing.T) {
+	for name, spec := range Specs {
+		db, err := ethdb.NewMemDatabase()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// genesis hash is checked by setup
+		cfg, err := spec.setup(db)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if cfg != spec.Config {
+			t.Errorf("%s: wrong config", name)
+		}
+		db.Close()
+	}
+}
+
+func TestSpecSetupDoesNotChangeGenesis(t *testing.T) {
+	spec := &Spec{Name: "test", Config: classicConfig, Genesis: mordorGenesis()}
+	spec.Genesis.Config = nil
+	db, _ := ethdb.NewMemDatabase()
+	defer db.Close()
+	if _, err := spec.setup(db); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Genesis.Config != nil {
+		t.Error("genesis of the spec is changed")
+	}
+}
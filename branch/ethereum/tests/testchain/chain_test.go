@@ -0,0 +1,43 @@
+package testchain
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sudachen/playground/branch/ethereum/chain"
+	et "github.com/sudachen/playground/playtool/ethereum"
+)
+
+var archivesDir = filepath.Join("..", "..", "..", "..", "testdata", "chain")
+
+func TestArchives(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(archivesDir, "*.rlp*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("there are no chain archives in testdata/chain")
+	}
+	for _, fn := range files {
+		fn := fn
+		t.Run(filepath.Base(fn), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "replay")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			err = et.Replay(&et.ReplayOptions{
+				Chain:     &chain.Options{Archive: fn},
+				StateDir:  filepath.Join(dir, "state"),
+				ReportDir: dir,
+			}, context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -53,12 +53,24 @@ func NewVM() libeth.VM {
 	return &nvm{}
 }
 
-func (*nvm) Execute(tx *libeth.Transaction, bi *libeth.BlockInfo, st libeth.State) (
+func (n *nvm) Execute(tx *libeth.Transaction, bi *libeth.BlockInfo, st libeth.State) (
 	/*out*/ []byte,
 	/*usedGas*/ *big.Int,
 	/*resultState*/ libeth.State,
 	/*executionError*/ error) {
 
+	x := n.Prepare(tx, bi, st)
+	out, usedGas, err := x.Run()
+	return out, usedGas, x.Finish(), err
+}
+
+type execution struct {
+	vm *sputnikvm.VM
+	rs *state.MicroState
+	bi *libeth.BlockInfo
+}
+
+func (*nvm) Prepare(tx *libeth.Transaction, bi *libeth.BlockInfo, st libeth.State) libeth.Execution {
 	rs := state.NewMicroState(st)
 
 	vmtx := sputnikvm.Transaction{
@@ -97,6 +109,12 @@ func (*nvm) Execute(tx *libeth.Transaction, bi *libeth.BlockInfo, st libeth.Stat
 		vm = sputnikvm.NewFrontier(&vmtx, &vmheader)
 	}
 
+	return &execution{vm, rs, bi}
+}
+
+func (x *execution) Run() ([]byte, *big.Int, error) {
+	vm, rs, bi := x.vm, x.rs, x.bi
+
 Loop:
 	for {
 		ret := vm.Fire()
@@ -137,6 +155,12 @@ Loop:
 		}
 	}
 
+	return nil, vm.UsedGas(), nil
+}
+
+func (x *execution) Finish() libeth.State {
+	vm, rs := x.vm, x.rs
+
 	// VM execution is finished at this point. We apply changes to the statedb.
 
 	for _, account := range vm.AccountChanges() {
@@ -181,8 +205,6 @@ Loop:
 		rs.AddLog(comAddress(log.Address), topics, log.Data)
 	}
 
-	usedGas := vm.UsedGas()
-
 	vm.Free()
-	return nil, usedGas, rs.Freeze(), nil
+	return rs.Freeze()
 }
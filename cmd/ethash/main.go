@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/sudachen/playground/crypto"
+	"github.com/sudachen/playground/ethash"
+)
+
+const usage = `usage: ethash <command> [flags]
+
+commands:
+  verify   verify the header given as JSON or RLP
+  seed     print the seed hash of the block epoch
+  sizes    print cache and dataset sizes of the block epoch
+  cache    generate the cache of the block epoch, dump it or print its checksum
+
+run 'ethash <command> -h' for command flags
+`
+
+// header is the part of the block header required by Light.Verify
+type header struct {
+	number      uint64
+	hashNoNonce common.Hash
+	nonce       uint64
+	mixDigest   common.Hash
+	difficulty  *big.Int
+}
+
+func (h *header) Difficulty() *big.Int     { return h.difficulty }
+func (h *header) HashNoNonce() common.Hash { return h.hashNoNonce }
+func (h *header) Nonce() uint64            { return h.nonce }
+func (h *header) MixDigest() common.Hash   { return h.mixDigest }
+func (h *header) NumberU64() uint64        { return h.number }
+
+// headerJSON has the nonce as 8 bytes hex as it's in headers of JSON-RPC
+type headerJSON struct {
+	Number      hexutil.Uint64   `json:"number"`
+	HashNoNonce common.Hash      `json:"hashNoNonce"`
+	Nonce       types.BlockNonce `json:"nonce"`
+	MixDigest   common.Hash      `json:"mixDigest"`
+	Difficulty  *hexutil.Big     `json:"difficulty"`
+}
+
+// readInput returns content of the file, stdin if fn is '-', or the argument itself
+func readInput(arg string) ([]byte, error) {
+	if arg == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return ioutil.ReadFile(arg)
+	}
+	return []byte(arg), nil
+}
+
+func parseJSON(b []byte) (*header, error) {
+	h := &headerJSON{}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, err
+	}
+	if h.Difficulty == nil {
+		return nil, fmt.Errorf("header does not have difficulty")
+	}
+	return &header{
+		number:      uint64(h.Number),
+		hashNoNonce: h.HashNoNonce,
+		nonce:       h.Nonce.Uint64(),
+		mixDigest:   h.MixDigest,
+		difficulty:  (*big.Int)(h.Difficulty),
+	}, nil
+}
+
+// parseRLP decodes the full block header, the input is hex or binary RLP
+func parseRLP(b []byte) (*header, error) {
+	if s := strings.TrimSpace(string(b)); len(s) > 0 && (strings.HasPrefix(s, "0x") || isHex(s)) {
+		b = common.FromHex(s)
+	}
+	eh := &types.Header{}
+	if err := rlp.DecodeBytes(b, eh); err != nil {
+		return nil, err
+	}
+	return &header{
+		number:      eh.Number.Uint64(),
+		hashNoNonce: eh.HashNoNonce(),
+		nonce:       eh.Nonce.Uint64(),
+		mixDigest:   eh.MixDigest,
+		difficulty:  eh.Difficulty,
+	}, nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	jsonArg := fs.String("json", "", "header JSON {number,hashNoNonce,nonce,mixDigest,difficulty}, file, '-' for stdin or literal")
+	rlpArg := fs.String("rlp", "", "RLP of the full header, file, '-' for stdin or hex literal")
+	ecip1099 := ecip1099Flag(fs)
+	fs.Parse(args)
+
+	var h *header
+	var err error
+	switch {
+	case *jsonArg != "":
+		var b []byte
+		if b, err = readInput(*jsonArg); err == nil {
+			h, err = parseJSON(b)
+		}
+	case *rlpArg != "":
+		var b []byte
+		if b, err = readInput(*rlpArg); err == nil {
+			h, err = parseRLP(b)
+		}
+	default:
+		return fmt.Errorf("either -json or -rlp is required")
+	}
+	if err != nil {
+		return fmt.Errorf("malformed header: %v", err)
+	}
+
+	fmt.Printf("number:      %d\n", h.NumberU64())
+	fmt.Printf("hashNoNonce: %s\n", h.HashNoNonce().Hex())
+	fmt.Printf("nonce:       %#x\n", h.Nonce())
+	fmt.Printf("mixDigest:   %s\n", h.MixDigest().Hex())
+	fmt.Printf("difficulty:  %v\n", h.Difficulty())
+	eth := ethash.New()
+	eth.ECIP1099Block = *ecip1099
+	// the header is verified on request, so any epoch is allowed
+	eth.SetHead(h.NumberU64())
+	if !eth.Verify(h) {
+		return fmt.Errorf("invalid proof of work")
+	}
+	fmt.Println("valid")
+	return nil
+}
+
+func blockFlag(name string) (*flag.FlagSet, *uint64) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	block := fs.Uint64("block", 0, "block number")
+	return fs, block
+}
+
+// ecip1099Flag is the block since which epoch length is doubled
+func ecip1099Flag(fs *flag.FlagSet) *uint64 {
+	return fs.Uint64("ecip1099", 0, fmt.Sprintf("block since which epoch length is doubled, %d on ETC mainnet", ethash.ECIP1099Block))
+}
+
+func seed(args []string) error {
+	fs, block := blockFlag("seed")
+	ecip1099 := ecip1099Flag(fs)
+	fs.Parse(args)
+	sh, err := ethash.GetSeedHash(*block, *ecip1099)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hexutil.Encode(sh))
+	return nil
+}
+
+func sizes(args []string) error {
+	fs, block := blockFlag("sizes")
+	ecip1099 := ecip1099Flag(fs)
+	fs.Parse(args)
+	c, d := ethash.CacheSize(*block, *ecip1099), ethash.DatasetSize(*block, *ecip1099)
+	if c == 0 || d == 0 {
+		return fmt.Errorf("block %d is beyond the maximal epoch", *block)
+	}
+	fmt.Printf("cache:   %d\n", c)
+	fmt.Printf("dataset: %d\n", d)
+	return nil
+}
+
+func cache(args []string) error {
+	fs, block := blockFlag("cache")
+	dump := fs.String("out", "", "write the cache into the file, nodes are little-endian words")
+	ecip1099 := ecip1099Flag(fs)
+	fs.Parse(args)
+	b, err := ethash.MakeCache(*block, *ecip1099)
+	if err != nil {
+		return err
+	}
+	if *dump != "" {
+		if err := ioutil.WriteFile(*dump, b, 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("size:      %d\n", len(b))
+	fmt.Printf("keccak256: %s\n", hexutil.Encode(crypto.Keccak256(b)))
+	return nil
+}
+
+var commands = map[string]func([]string) error{
+	"verify": verify,
+	"seed":   seed,
+	"sizes":  sizes,
+	"cache":  cache,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", os.Args[1], usage)
+		os.Exit(1)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/sudachen/playground/ethash"
+)
+
+// from proof of concept nine testnet, epoch 0
+const testHeaderJSON = `{
+	"number": "0x16",
+	"hashNoNonce": "0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d",
+	"nonce": "0x495732e0ed7a801c",
+	"mixDigest": "0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5",
+	"difficulty": "0x20540"
+}`
+
+func TestParseJSON(t *testing.T) {
+	h, err := parseJSON([]byte(testHeaderJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.number != 22 || h.nonce != 0x495732e0ed7a801c || h.difficulty.Int64() != 132416 ||
+		h.hashNoNonce != common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d") ||
+		h.mixDigest != common.HexToHash("0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5") {
+		t.Fatalf("wrong header %+v", h)
+	}
+	if !ethash.New().Verify(h) {
+		t.Error("decoded header is not verified")
+	}
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	for _, s := range []string{
+		`{"number": "0x16", "nonce": "0x495732e0ed7a801c"}`,                        // no difficulty
+		`{"number": "0x16", "nonce": "0x1", "difficulty": "0x20540"}`,              // nonce is not 8 bytes
+		`{"number": "0x16", "nonce": "0x495732e0ed7a801c00", "difficulty": "0x1"}`, // nonce is too long
+		`{"number": 22, "nonce": "0x495732e0ed7a801c", "difficulty": "0x20540"}`,   // number is not hex
+	} {
+		if _, err := parseJSON([]byte(s)); err == nil {
+			t.Errorf("malformed header is decoded: %s", s)
+		}
+	}
+}
+
+// the nonce is encoded by go-ethereum, so headers of JSON-RPC are accepted
+func TestParseJSONNonce(t *testing.T) {
+	nonce := types.EncodeNonce(0x1c)
+	b, err := json.Marshal(map[string]interface{}{
+		"number":     hexutil.Uint64(1),
+		"nonce":      nonce,
+		"difficulty": (*hexutil.Big)(big.NewInt(1)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.nonce != 0x1c {
+		t.Errorf("wrong nonce %#x of %s", h.nonce, b)
+	}
+}
+
+func TestParseRLP(t *testing.T) {
+	eh := &types.Header{
+		Number:     big.NewInt(22),
+		Difficulty: big.NewInt(132416),
+		Time:       big.NewInt(1),
+		MixDigest:  common.HexToHash("0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5"),
+		Nonce:      types.EncodeNonce(0x495732e0ed7a801c),
+	}
+	b, err := rlp.EncodeToBytes(eh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range [][]byte{b, []byte(hexutil.Encode(b)), []byte(common.Bytes2Hex(b) + "\n")} {
+		h, err := parseRLP(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.number != 22 || h.nonce != 0x495732e0ed7a801c || h.difficulty.Int64() != 132416 ||
+			h.hashNoNonce != eh.HashNoNonce() || h.mixDigest != eh.MixDigest {
+			t.Errorf("wrong header %+v", h)
+		}
+	}
+}
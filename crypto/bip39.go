@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrEntropyLength    = errors.New("entropy length must be 128 to 256 bits and a multiple of 32")
+	ErrInvalidMnemonic  = errors.New("invalid mnemonic")
+	ErrMnemonicChecksum = errors.New("mnemonic checksum mismatch")
+)
+
+var bip39Index = func() map[string]int {
+	m := make(map[string]int, len(bip39English))
+	for i, w := range bip39English {
+		m[w] = i
+	}
+	return m
+}()
+
+// NewMnemonic generates the random BIP-39 mnemonic with bits of entropy,
+// 128 bits give 12 words and 256 bits give 24 words
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrEntropyLength
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes the entropy with its checksum as the English mnemonic
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrEntropyLength
+	}
+	// checksum is up to 8 first bits of the hash
+	h := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), h[0])
+
+	words := make([]string, (bits+bits/32)/11)
+	for i := range words {
+		index := 0
+		for j := 0; j < 11; j++ {
+			pos := i*11 + j
+			index = index<<1 | int(data[pos/8]>>uint(7-pos%8)&1)
+		}
+		words[i] = bip39English[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes the mnemonic and verifies its checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrInvalidMnemonic
+	}
+	data := make([]byte, (len(words)*11+7)/8)
+	for i, w := range words {
+		index, ok := bip39Index[w]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		for j := 0; j < 11; j++ {
+			if index&(1<<uint(10-j)) != 0 {
+				pos := i*11 + j
+				data[pos/8] |= 1 << uint(7-pos%8)
+			}
+		}
+	}
+	bits := len(words) * 11 * 32 / 33
+	entropy := data[:bits/8]
+	h := sha256.Sum256(entropy)
+	cs := uint(bits / 32)
+	if h[0]>>(8-cs) != data[bits/8]>>(8-cs) {
+		return nil, ErrMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// MnemonicToSeed verifies the mnemonic and returns the 64 bytes BIP-39 seed,
+// the mnemonic and the passphrase are expected to be in NFKD form
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
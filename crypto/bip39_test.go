@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BIP-39 test vectors with the TREZOR passphrase
+// https://github.com/trezor/python-mnemonic/blob/master/vectors.json
+var bip39Vectors = []struct {
+	entropy, mnemonic, seed string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"80808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	},
+	{
+		"ffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal will",
+		"f2b94508732bcbacbcc020faefecfc89feafa6649a5491b8c952cede496c214a0c7b3c392d168748f2d4a612bada0753b52a1c7ac53c1e93abd5c6320b9e95dd",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	},
+}
+
+func TestBIP39Vectors(t *testing.T) {
+	if len(bip39English) != 2048 {
+		t.Fatalf("wrong word list length %d", len(bip39English))
+	}
+	for _, v := range bip39Vectors {
+		mnemonic, err := EntropyToMnemonic(common.FromHex(v.entropy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != v.mnemonic {
+			t.Errorf("%s: mnemonic mismatch: have %q", v.entropy, mnemonic)
+		}
+		entropy, err := MnemonicToEntropy(v.mnemonic)
+		if err != nil || !bytes.Equal(entropy, common.FromHex(v.entropy)) {
+			t.Errorf("%s: entropy mismatch: have %x, %v", v.entropy, entropy, err)
+		}
+		seed, err := MnemonicToSeed(v.mnemonic, "TREZOR")
+		if err != nil || !bytes.Equal(seed, common.FromHex(v.seed)) {
+			t.Errorf("%s: seed mismatch: have %x, %v", v.entropy, seed, err)
+		}
+	}
+}
+
+func TestInvalidMnemonic(t *testing.T) {
+	for _, c := range []struct {
+		mnemonic string
+		err      error
+	}{
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", ErrMnemonicChecksum},
+		{"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo", ErrMnemonicChecksum},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", ErrInvalidMnemonic},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon aboutt", ErrInvalidMnemonic},
+		{"", ErrInvalidMnemonic},
+	} {
+		if _, err := MnemonicToSeed(c.mnemonic, ""); err != c.err {
+			t.Errorf("%q: wrong error %v", c.mnemonic, err)
+		}
+	}
+	if _, err := EntropyToMnemonic(make([]byte, 15)); err != ErrEntropyLength {
+		t.Errorf("wrong error %v", err)
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		mnemonic, err := NewMnemonic(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(strings.Fields(mnemonic)); n != bits/32*3 {
+			t.Errorf("%d bits: wrong words count %d", bits, n)
+		}
+		// extra spaces are ignored
+		if _, err := MnemonicToSeed(" "+strings.Replace(mnemonic, " ", "  ", -1), ""); err != nil {
+			t.Errorf("%d bits: %v", bits, err)
+		}
+	}
+	if _, err := NewMnemonic(100); err != ErrEntropyLength {
+		t.Errorf("wrong error %v", err)
+	}
+}
@@ -27,7 +27,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/sudachen/crypto/secp256k1"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -73,8 +72,8 @@ func BenchmarkSha3(b *testing.B) {
 }
 
 func Test0Key(t *testing.T) {
-	key := common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000000")
-	_, err := secp256k1.GeneratePubKey(key)
+	key := ToECDSA(common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000000"))
+	_, err := Sign(Keccak256([]byte("foo")), key)
 	if err == nil {
 		t.Errorf("expected error due to zero privkey")
 	}
@@ -122,6 +121,44 @@ func TestInvalidSign(t *testing.T) {
 	}
 }
 
+// signatures are deterministic, both backends produce the same bytes
+func TestSignVector(t *testing.T) {
+	key, _ := HexToECDSA(testPrivHex)
+	sig, err := Sign(Keccak256([]byte("foo")), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := common.FromHex("d155e94305af7e07dd8c32873e5c03cb95c9e05960ef85be9c07f671da58c73718c19adc397a211aa9e87e519e2038c5a3b658618db335f74f800b8e0cfeef4401")
+	if !bytes.Equal(sig, exp) {
+		t.Errorf("signature mismatch: want: %x have: %x", exp, sig)
+	}
+}
+
+func TestInvalidRecover(t *testing.T) {
+	key, _ := HexToECDSA(testPrivHex)
+	msg := Keccak256([]byte("foo"))
+	sig, _ := Sign(msg, key)
+
+	badRecid := common.CopyBytes(sig)
+	badRecid[64] = 4
+	zeroR := common.CopyBytes(sig)
+	copy(zeroR[:32], make([]byte, 32))
+
+	for name, c := range map[string]struct{ msg, sig []byte }{
+		"short hash":      {msg[:31], sig},
+		"short signature": {msg, sig[:64]},
+		"recovery id":     {msg, badRecid},
+		"zero r":          {msg, zeroR},
+	} {
+		if _, err := Ecrecover(c.msg, c.sig); err == nil {
+			t.Errorf("%s: expected Ecrecover to error", name)
+		}
+		if _, err := SigToPub(c.msg, c.sig); err == nil {
+			t.Errorf("%s: expected SigToPub to error", name)
+		}
+	}
+}
+
 func TestNewContractAddress(t *testing.T) {
 	key, _ := HexToECDSA(testPrivHex)
 	addr := common.HexToAddress(testAddrHex)
@@ -181,7 +218,7 @@ func TestValidateSignatureValues(t *testing.T) {
 	minusOne := big.NewInt(-1)
 	one := big.NewInt(1)
 	zero := new(big.Int)
-	secp256k1nMinus1 := new(big.Int).Sub(secp256k1.N, one)
+	secp256k1nMinus1 := new(big.Int).Sub(secp256k1N, one)
 
 	// correct v,r,s
 	check(true, 27, one, one)
@@ -208,9 +245,9 @@ func TestValidateSignatureValues(t *testing.T) {
 	// correct sig with max r,s
 	check(true, 27, secp256k1nMinus1, secp256k1nMinus1)
 	// correct v, combinations of incorrect r,s at upper limit
-	check(false, 27, secp256k1.N, secp256k1nMinus1)
-	check(false, 27, secp256k1nMinus1, secp256k1.N)
-	check(false, 27, secp256k1.N, secp256k1.N)
+	check(false, 27, secp256k1N, secp256k1nMinus1)
+	check(false, 27, secp256k1nMinus1, secp256k1N)
+	check(false, 27, secp256k1N, secp256k1N)
 
 	// current callers ensures r,s cannot be negative, but let's test for that too
 	// as crypto package could be used stand-alone
@@ -239,10 +276,10 @@ func TestPythonIntegration(t *testing.T) {
 	k1 := FromECDSA(k0)
 
 	msg0 := Keccak256([]byte("foo"))
-	sig0, _ := secp256k1.Sign(msg0, k1)
+	sig0, _ := Sign(msg0, k0)
 
 	msg1 := common.FromHex("00000000000000000000000000000000")
-	sig1, _ := secp256k1.Sign(msg0, k1)
+	sig1, _ := Sign(msg0, k0)
 
 	fmt.Printf("msg: %x, privkey: %x sig: %x\n", msg0, k1, sig0)
 	fmt.Printf("msg: %x, privkey: %x sig: %x\n", msg1, k1, sig1)
@@ -0,0 +1,207 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HardenedKeyStart is the index of the first hardened child key
+const HardenedKeyStart uint32 = 0x80000000
+
+var (
+	ErrInvalidSeed  = errors.New("seed length must be 128 to 512 bits")
+	ErrInvalidChild = errors.New("invalid child key, use the next index")
+)
+
+// DerivationPath is the BIP-32 path of the key from the master key
+type DerivationPath []uint32
+
+// BIP-44 paths of external accounts, the account index is appended to the path
+var (
+	ClassicBasePath  = DerivationPath{HardenedKeyStart + 44, HardenedKeyStart + 61, HardenedKeyStart, 0}
+	EthereumBasePath = DerivationPath{HardenedKeyStart + 44, HardenedKeyStart + 60, HardenedKeyStart, 0}
+)
+
+// ParseDerivationPath parses the path like m/44'/61'/0'/0/0
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if components[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+	var result DerivationPath
+	for _, c := range components[1:] {
+		base := uint32(0)
+		if strings.HasSuffix(c, "'") {
+			base = HardenedKeyStart
+			c = strings.TrimSuffix(c, "'")
+		}
+		index, err := strconv.ParseUint(c, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid component %q of derivation path %q", c, path)
+		}
+		result = append(result, base+uint32(index))
+	}
+	return result, nil
+}
+
+func (p DerivationPath) String() string {
+	s := "m"
+	for _, i := range p {
+		if i >= HardenedKeyStart {
+			s += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+		} else {
+			s += fmt.Sprintf("/%d", i)
+		}
+	}
+	return s
+}
+
+// Child returns the path of the child key with index i
+func (p DerivationPath) Child(i uint32) DerivationPath {
+	return append(append(DerivationPath{}, p...), i)
+}
+
+// HDKey is the BIP-32 extended private key
+type HDKey struct {
+	PrivateKey *ecdsa.PrivateKey
+	ChainCode  []byte
+	Depth      byte
+	Index      uint32
+}
+
+func newHDKey(i []byte, depth byte, index uint32) (*HDKey, error) {
+	d := new(big.Int).SetBytes(i[:32])
+	if d.Sign() == 0 || d.Cmp(secp256k1N) >= 0 {
+		return nil, ErrInvalidChild
+	}
+	return &HDKey{
+		PrivateKey: ToECDSA(i[:32]),
+		ChainCode:  i[32:],
+		Depth:      depth,
+		Index:      index,
+	}, nil
+}
+
+// NewMasterKey creates the master key from the seed
+func NewMasterKey(seed []byte) (*HDKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrInvalidSeed
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	return newHDKey(mac.Sum(nil), 0, 0)
+}
+
+func compressPubkey(pub *ecdsa.PublicKey) []byte {
+	b := make([]byte, 33)
+	b[0] = 2 + byte(pub.Y.Bit(0))
+	x := pub.X.Bytes()
+	copy(b[33-len(x):], x)
+	return b
+}
+
+// Child derives the child private key, indexes from HardenedKeyStart
+// give hardened keys. ErrInvalidChild is returned with negligible probability
+func (k *HDKey) Child(index uint32) (*HDKey, error) {
+	data := make([]byte, 0, 37)
+	if index >= HardenedKeyStart {
+		data = append(data, 0)
+		data = append(data, common.LeftPadBytes(k.PrivateKey.D.Bytes(), 32)...)
+	} else {
+		data = append(data, compressPubkey(&k.PrivateKey.PublicKey)...)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	i := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(i[:32])
+	if il.Cmp(secp256k1N) >= 0 {
+		return nil, ErrInvalidChild
+	}
+	il.Add(il, k.PrivateKey.D)
+	il.Mod(il, secp256k1N)
+	copy(i[:32], common.LeftPadBytes(il.Bytes(), 32))
+	return newHDKey(i, k.Depth+1, index)
+}
+
+// Derive derives the key by the path relative to this key
+func (k *HDKey) Derive(path DerivationPath) (key *HDKey, err error) {
+	key = k
+	for _, index := range path {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// HDWallet derives accounts from the BIP-39 mnemonic by BIP-44 path,
+// so test networks and fuzzers can have any number of funded accounts
+// generated from one phrase
+type HDWallet struct {
+	Base DerivationPath
+	base *HDKey
+}
+
+// NewHDWallet creates the wallet, ClassicBasePath is used if base is nil
+func NewHDWallet(mnemonic, passphrase string, base DerivationPath) (*HDWallet, error) {
+	if base == nil {
+		base = ClassicBasePath
+	}
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.Derive(base)
+	if err != nil {
+		return nil, err
+	}
+	return &HDWallet{Base: base, base: key}, nil
+}
+
+// PrivateKey returns the key of the account with index i
+func (w *HDWallet) PrivateKey(i uint32) (*ecdsa.PrivateKey, error) {
+	key, err := w.base.Child(i)
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// Address returns the address of the account with index i
+func (w *HDWallet) Address(i uint32) (common.Address, error) {
+	key, err := w.PrivateKey(i)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return PubkeyToAddress(key.PublicKey), nil
+}
+
+// Keys returns keys of the first n accounts
+func (w *HDWallet) Keys(n int) ([]*ecdsa.PrivateKey, error) {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		key, err := w.PrivateKey(uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
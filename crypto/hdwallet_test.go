@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BIP-32 test vector 1
+// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vector-1
+func TestBIP32Vector(t *testing.T) {
+	master, err := NewMasterKey(common.FromHex("000102030405060708090a0b0c0d0e0f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(master.ChainCode, common.FromHex("873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508")) {
+		t.Errorf("master chain code mismatch: have %x", master.ChainCode)
+	}
+	for _, v := range []struct {
+		path, key string
+	}{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"m/0'/1/2'/2", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+		{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	} {
+		path, err := ParseDerivationPath(v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path.String() != v.path {
+			t.Errorf("path mismatch: have %s, want %s", path, v.path)
+		}
+		key, err := master.Derive(path)
+		if err != nil {
+			t.Fatalf("%s: %v", v.path, err)
+		}
+		if int(key.Depth) != len(path) {
+			t.Errorf("%s: wrong depth %d", v.path, key.Depth)
+		}
+		if have := common.LeftPadBytes(key.PrivateKey.D.Bytes(), 32); !bytes.Equal(have, common.FromHex(v.key)) {
+			t.Errorf("%s: key mismatch: have %x", v.path, have)
+		}
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath("m/44'/61'/0'/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.String() != ClassicBasePath.String() {
+		t.Errorf("path mismatch: have %s", path)
+	}
+	if s := EthereumBasePath.Child(5).String(); s != "m/44'/60'/0'/0/5" {
+		t.Errorf("path mismatch: have %s", s)
+	}
+	for _, s := range []string{"", "44'/61'", "m/", "m/x", "m/-1", "m/2147483648", "m/0''"} {
+		if _, err := ParseDerivationPath(s); err == nil {
+			t.Errorf("invalid path %q is parsed", s)
+		}
+	}
+}
+
+func TestHDWallet(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	// the well known first account of the mnemonic
+	eth, err := NewHDWallet(mnemonic, "", EthereumBasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := eth.Address(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAddr(t, addr, common.HexToAddress("9858EfFD232B4033E47d90003D41EC34EcaEda94"))
+
+	etc, err := NewHDWallet(mnemonic, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := etc.Keys(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, _ := MnemonicToSeed(mnemonic, "")
+	master, _ := NewMasterKey(seed)
+	for i, key := range keys {
+		k, err := master.Derive(ClassicBasePath.Child(uint32(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.PrivateKey.D.Cmp(key.D) != 0 {
+			t.Errorf("account %d: key mismatch", i)
+		}
+		if addr, _ := eth.Address(uint32(i)); addr == PubkeyToAddress(key.PublicKey) {
+			t.Errorf("account %d: the same address for ETC and ETH", i)
+		}
+		// keys are usable for signing
+		msg := Keccak256([]byte("foo"))
+		sig, err := Sign(msg, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := SigToPub(msg, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAddr(t, PubkeyToAddress(*pub), PubkeyToAddress(key.PublicKey))
+	}
+
+	if _, err := NewHDWallet(mnemonic+" about", "", nil); err != ErrInvalidMnemonic {
+		t.Errorf("wrong error %v", err)
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright 2014 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Keys are stored in Web3 Secret Storage format version 3, see
+// https://github.com/ethereum/wiki/wiki/Web3-Secret-Storage-Definition
+
+const (
+	keyVersion = 3
+
+	// StandardScryptN is the N parameter of Scrypt encryption algorithm, using 256MB
+	// memory and taking approximately 1s CPU time on a modern processor.
+	StandardScryptN = 1 << 18
+
+	// StandardScryptP is the P parameter of Scrypt encryption algorithm, using 256MB
+	// memory and taking approximately 1s CPU time on a modern processor.
+	StandardScryptP = 1
+
+	// LightScryptN is the N parameter of Scrypt encryption algorithm, using 4MB
+	// memory and taking approximately 100ms CPU time on a modern processor.
+	LightScryptN = 1 << 12
+
+	// LightScryptP is the P parameter of Scrypt encryption algorithm, using 4MB
+	// memory and taking approximately 100ms CPU time on a modern processor.
+	LightScryptP = 6
+
+	// StandardPbkdf2C is the iterations count of PBKDF2 key derivation
+	StandardPbkdf2C = 262144
+
+	scryptR  = 8
+	kdfDKLen = 32
+)
+
+var ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+// Key is the private key with its address, Id is the random UUID of the key file
+type Key struct {
+	Id         [16]byte
+	Address    common.Address
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// NewKey wraps the private key, it gets a new random UUID
+func NewKey(prv *ecdsa.PrivateKey) *Key {
+	key := &Key{Address: PubkeyToAddress(prv.PublicKey), PrivateKey: prv}
+	rand.Read(key.Id[:])
+	key.Id[6] = key.Id[6]&0x0f | 0x40 // version 4
+	key.Id[8] = key.Id[8]&0x3f | 0x80 // variant RFC4122
+	return key
+}
+
+func (k *Key) uuid() string {
+	h := hex.EncodeToString(k.Id[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+type keyJSON struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Id      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string           `json:"cipher"`
+	CipherText   string           `json:"ciphertext"`
+	CipherParams cipherparamsJSON `json:"cipherparams"`
+	KDF          string           `json:"kdf"`
+	KDFParams    kdfparamsJSON    `json:"kdfparams"`
+	MAC          string           `json:"mac"`
+}
+
+type cipherparamsJSON struct {
+	IV string `json:"iv"`
+}
+
+// kdfparamsJSON has parameters of both scrypt (n,r,p) and pbkdf2 (c,prf)
+type kdfparamsJSON struct {
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+}
+
+func (p *kdfparamsJSON) deriveKey(kdf, auth string) ([]byte, error) {
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if p.DKLen < 32 {
+		return nil, fmt.Errorf("derived key is too short: %d", p.DKLen)
+	}
+	switch kdf {
+	case "scrypt":
+		return scrypt.Key([]byte(auth), salt, p.N, p.R, p.P, p.DKLen)
+	case "pbkdf2":
+		if p.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF: %s", p.PRF)
+		}
+		return pbkdf2.Key([]byte(auth), salt, p.C, p.DKLen, sha256.New), nil
+	}
+	return nil, fmt.Errorf("unsupported KDF: %s", kdf)
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(aesBlock, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, err
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func encryptKey(key *Key, auth, kdf string, params kdfparamsJSON) ([]byte, error) {
+	salt, err := randomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	params.Salt = hex.EncodeToString(salt)
+	params.DKLen = kdfDKLen
+	derivedKey, err := params.deriveKey(kdf, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := common.LeftPadBytes(key.PrivateKey.D.Bytes(), 32)
+	defer zeroBytes(keyBytes)
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aesCTRXOR(derivedKey[:16], keyBytes, iv)
+	if err != nil {
+		return nil, err
+	}
+	mac := Keccak256(derivedKey[16:32], cipherText)
+
+	return json.Marshal(&keyJSON{
+		Address: hex.EncodeToString(key.Address[:]),
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherparamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          kdf,
+			KDFParams:    params,
+			MAC:          hex.EncodeToString(mac),
+		},
+		Id:      key.uuid(),
+		Version: keyVersion,
+	})
+}
+
+// EncryptKey encrypts the key using the specified scrypt parameters into
+// the JSON which can be decrypted later on.
+func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
+	return encryptKey(key, auth, "scrypt", kdfparamsJSON{N: scryptN, R: scryptR, P: scryptP})
+}
+
+// EncryptKeyPbkdf2 encrypts the key using PBKDF2 with c iterations,
+// scrypt is preferred, PBKDF2 is supported for compatibility
+func EncryptKeyPbkdf2(key *Key, auth string, c int) ([]byte, error) {
+	return encryptKey(key, auth, "pbkdf2", kdfparamsJSON{C: c, PRF: "hmac-sha256"})
+}
+
+// DecryptKey decrypts the key from the JSON, ErrDecrypt is returned
+// if the passphrase is wrong
+func DecryptKey(keyjson []byte, auth string) (*Key, error) {
+	k := &keyJSON{}
+	if err := json.Unmarshal(keyjson, k); err != nil {
+		return nil, err
+	}
+	if k.Version != keyVersion {
+		return nil, fmt.Errorf("version not supported: %v", k.Version)
+	}
+	if k.Crypto.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("cipher not supported: %v", k.Crypto.Cipher)
+	}
+
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid iv length %d", len(iv))
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	// old go-ethereum stores keys without leading zeros, so the ciphertext is shorter
+	if len(cipherText) == 0 || len(cipherText) > 32 {
+		return nil, fmt.Errorf("invalid ciphertext length %d", len(cipherText))
+	}
+	derivedKey, err := k.Crypto.KDFParams.deriveKey(k.Crypto.KDF, auth)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+	keyBytes, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(keyBytes)
+	if d := new(big.Int).SetBytes(keyBytes); d.Sign() == 0 || d.Cmp(secp256k1N) >= 0 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	// leading zeros of the key are not always stored
+	padded := common.LeftPadBytes(keyBytes, 32)
+	prv := ToECDSA(padded)
+	zeroBytes(padded)
+
+	key := &Key{Address: PubkeyToAddress(prv.PublicKey), PrivateKey: prv}
+	if id, err := hex.DecodeString(strings.Replace(k.Id, "-", "", -1)); err == nil && len(id) == len(key.Id) {
+		copy(key.Id[:], id)
+	}
+	return key, nil
+}
+
+// Account is the key file in the keystore directory
+type Account struct {
+	Address common.Address
+	File    string
+}
+
+// KeyStore is the directory of encrypted key files, it's compatible
+// with the keystore directory of geth
+type KeyStore struct {
+	Dir     string
+	ScryptN int // StandardScryptN if zero
+	ScryptP int // StandardScryptP if zero
+}
+
+// Accounts lists key files of the directory sorted by file name,
+// hidden files, directories and files without address are skipped
+func (ks *KeyStore) Accounts() ([]Account, error) {
+	files, err := ioutil.ReadDir(ks.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []Account
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || strings.HasSuffix(fi.Name(), "~") {
+			continue
+		}
+		fn := filepath.Join(ks.Dir, fi.Name())
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		var k struct {
+			Address string `json:"address"`
+		}
+		if json.Unmarshal(b, &k) != nil {
+			continue
+		}
+		addr, err := hex.DecodeString(strings.TrimPrefix(k.Address, "0x"))
+		if err != nil || len(addr) != common.AddressLength {
+			continue
+		}
+		accounts = append(accounts, Account{common.BytesToAddress(addr), fn})
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].File < accounts[j].File })
+	return accounts, nil
+}
+
+// Find returns the key file of the address
+func (ks *KeyStore) Find(addr common.Address) (Account, error) {
+	accounts, err := ks.Accounts()
+	if err != nil {
+		return Account{}, err
+	}
+	for _, a := range accounts {
+		if a.Address == addr {
+			return a, nil
+		}
+	}
+	return Account{}, fmt.Errorf("no key for address %x", addr)
+}
+
+// Unlock decrypts the key of the address by the passphrase
+func (ks *KeyStore) Unlock(addr common.Address, auth string) (*ecdsa.PrivateKey, error) {
+	a, err := ks.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(a.File)
+	if err != nil {
+		return nil, err
+	}
+	key, err := DecryptKey(b, auth)
+	if err != nil {
+		return nil, err
+	}
+	// make sure the file is not swapped
+	if key.Address != addr {
+		return nil, fmt.Errorf("key content mismatch: have account %x, want %x", key.Address, addr)
+	}
+	return key.PrivateKey, nil
+}
+
+// Store encrypts the key by the passphrase and writes it into a new file
+func (ks *KeyStore) Store(prv *ecdsa.PrivateKey, auth string) (Account, error) {
+	n, p := ks.ScryptN, ks.ScryptP
+	if n == 0 {
+		n = StandardScryptN
+	}
+	if p == 0 {
+		p = StandardScryptP
+	}
+	key := NewKey(prv)
+	b, err := EncryptKey(key, auth, n, p)
+	if err != nil {
+		return Account{}, err
+	}
+	a := Account{key.Address, filepath.Join(ks.Dir, keyFileName(key.Address))}
+	return a, writeKeyFile(a.File, b)
+}
+
+// NewAccount generates a new key and stores it
+func (ks *KeyStore) NewAccount(auth string) (Account, error) {
+	prv, err := GenerateKey()
+	if err != nil {
+		return Account{}, err
+	}
+	return ks.Store(prv, auth)
+}
+
+// keyFileName is the same as geth uses: UTC--<created_at UTC ISO8601>--<address hex>
+func keyFileName(addr common.Address) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--%s", strings.Replace(ts.Format("2006-01-02T15:04:05.999999999Z07:00"), ":", "-", -1), hex.EncodeToString(addr[:]))
+}
+
+// writeKeyFile writes temporary file and renames it, so the key file is never incomplete
+func writeKeyFile(file string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	if err := os.Rename(f.Name(), file); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
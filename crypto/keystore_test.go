@@ -0,0 +1,208 @@
+// Copyright 2014 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	veryLightScryptN = 2
+	veryLightScryptP = 1
+)
+
+type keyTestVector struct {
+	Json     json.RawMessage
+	Password string
+	Priv     string
+}
+
+// TestV3Vectors decrypts keys generated by go-ethereum and the wiki test vectors
+func TestV3Vectors(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/v3_test_vector.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := make(map[string]*keyTestVector)
+	if err := json.Unmarshal(b, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range vectors {
+		key, err := DecryptKey(v.Json, v.Password)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if key.PrivateKey.D.Cmp(new(big.Int).SetBytes(common.FromHex(v.Priv))) != 0 {
+			t.Errorf("%s: decrypted key mismatch: have %x, want %v", name, key.PrivateKey.D, v.Priv)
+		}
+		if _, err := DecryptKey(v.Json, v.Password+"bad"); err != ErrDecrypt {
+			t.Errorf("%s: key decrypted with bad password: %v", name, err)
+		}
+	}
+}
+
+// Tests that a json key file can be decrypted and encrypted in multiple rounds.
+func TestKeyEncryptDecrypt(t *testing.T) {
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := ""
+	address := common.HexToAddress("45dea0fb0bba44f4fcf290bba71fd57d7117cbb8")
+
+	// Do a few rounds of decryption and encryption
+	for i := 0; i < 3; i++ {
+		// Try a bad password first
+		if _, err := DecryptKey(keyjson, password+"bad"); err == nil {
+			t.Errorf("test %d: json key decrypted with bad password", i)
+		}
+		// Decrypt with the correct password
+		key, err := DecryptKey(keyjson, password)
+		if err != nil {
+			t.Fatalf("test %d: json key failed to decrypt: %v", i, err)
+		}
+		if key.Address != address {
+			t.Errorf("test %d: key address mismatch: have %x, want %x", i, key.Address, address)
+		}
+		// Recrypt with a new password and start over, pbkdf2 is used on odd rounds
+		password += "new data appended"
+		if i%2 == 0 {
+			keyjson, err = EncryptKey(key, password, veryLightScryptN, veryLightScryptP)
+		} else {
+			keyjson, err = EncryptKeyPbkdf2(key, password, 16)
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to recrypt key %v", i, err)
+		}
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := &KeyStore{Dir: dir, ScryptN: veryLightScryptN, ScryptP: veryLightScryptP}
+	a1, err := ks.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := ks.Store(ToECDSA(common.FromHex(testPrivHex)), "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAddr(t, a2.Address, common.HexToAddress(testAddrHex))
+
+	// files which are not keys are skipped
+	ioutil.WriteFile(filepath.Join(dir, "garbage"), []byte("{"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "no-address"), []byte(`{"version":3}`), 0600)
+	os.Mkdir(filepath.Join(dir, "foo"), 0700)
+
+	accounts, err := ks.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("wrong accounts count %d", len(accounts))
+	}
+	for _, a := range []Account{a1, a2} {
+		if found, err := ks.Find(a.Address); err != nil || found != a {
+			t.Errorf("account %x is not found: %v", a.Address, err)
+		}
+	}
+
+	prv, err := ks.Unlock(a2.Address, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prv.D.Cmp(new(big.Int).SetBytes(common.FromHex(testPrivHex))) != 0 {
+		t.Error("unlocked key mismatch")
+	}
+	if _, err := ks.Unlock(a1.Address, "bar"); err != ErrDecrypt {
+		t.Errorf("account is unlocked with wrong passphrase: %v", err)
+	}
+	if _, err := ks.Unlock(common.Address{}, "foo"); err == nil {
+		t.Error("unknown account is unlocked")
+	}
+}
+
+func TestDecryptKeyMalformed(t *testing.T) {
+	prv, err := HexToECDSA(testPrivHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, err := EncryptKey(NewKey(prv), "foo", veryLightScryptN, veryLightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var short map[string]interface{}
+	if err := json.Unmarshal(good, &short); err != nil {
+		t.Fatal(err)
+	}
+	short["crypto"].(map[string]interface{})["cipherparams"] = map[string]string{"iv": "0011"}
+	shortIV, _ := json.Marshal(short)
+
+	// the scalars are encrypted with the valid mac, so only the checks of the key reject them
+	encrypt := func(d *big.Int) []byte {
+		b, err := encryptKey(&Key{PrivateKey: &ecdsa.PrivateKey{D: d}}, "foo", "scrypt",
+			kdfparamsJSON{N: veryLightScryptN, R: scryptR, P: veryLightScryptP})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	for name, keyjson := range map[string][]byte{
+		"short iv":        shortIV,
+		"zero key":        encrypt(new(big.Int)),
+		"key is N":        encrypt(secp256k1N),
+		"long ciphertext": encrypt(new(big.Int).Lsh(big.NewInt(1), 264)),
+	} {
+		if _, err := DecryptKey(keyjson, "foo"); err == nil || err == ErrDecrypt {
+			t.Errorf("%s: malformed key is decrypted: %v", name, err)
+		}
+	}
+
+	// the malformed key file is not unlocked in the keystore
+	dir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ks := &KeyStore{Dir: dir, ScryptN: veryLightScryptN, ScryptP: veryLightScryptP}
+	addr := PubkeyToAddress(prv.PublicKey)
+	if err := ioutil.WriteFile(filepath.Join(dir, keyFileName(addr)), shortIV, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Find(addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Unlock(addr, "foo"); err == nil {
+		t.Error("malformed key file is unlocked")
+	}
+}
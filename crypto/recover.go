@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Signature is the signature to recover, Key identifies it in the cache,
+// usually it's the hash of the signed transaction
+type Signature struct {
+	Key  common.Hash // the cache is not used if it's zero
+	Hash []byte      // signed hash
+	Sig  []byte      // [R || S || V] format, V is 0 or 1
+}
+
+// Recovered is the result of the signature recovery
+type Recovered struct {
+	Pub     []byte // uncompressed public key
+	Address common.Address
+	Err     error
+}
+
+// DefaultRecoverCacheSize is the count of cached signatures of DefaultRecoverer
+const DefaultRecoverCacheSize = 1 << 16
+
+// Recoverer recovers signatures by the pool of workers and keeps
+// recovered keys in the LRU cache, it's safe for concurrent use.
+// Workers are shared by concurrent batches, so callers recovering
+// batches in parallel do not multiply them.
+type Recoverer struct {
+	Workers int // NumCPU by default, it's read by the first batch
+
+	mu    sync.Mutex
+	size  int
+	lru   *list.List
+	items map[common.Hash]*list.Element
+
+	once    sync.Once
+	helpers chan struct{} // slots of goroutines helping callers of batches
+}
+
+type recoverEntry struct {
+	key common.Hash
+	r   Recovered
+}
+
+// NewRecoverer creates the recoverer with the cache of size signatures,
+// the cache is disabled if size is zero
+func NewRecoverer(workers, size int) *Recoverer {
+	return &Recoverer{
+		Workers: workers,
+		size:    size,
+		lru:     list.New(),
+		items:   make(map[common.Hash]*list.Element),
+	}
+}
+
+// DefaultRecoverer is used by RecoverBatch
+var DefaultRecoverer = NewRecoverer(0, DefaultRecoverCacheSize)
+
+// RecoverBatch recovers signatures by DefaultRecoverer
+func RecoverBatch(sigs []Signature) []Recovered {
+	return DefaultRecoverer.RecoverBatch(sigs)
+}
+
+func (rc *Recoverer) workers() int {
+	if rc.Workers > 0 {
+		return rc.Workers
+	}
+	return runtime.NumCPU()
+}
+
+func (rc *Recoverer) get(key common.Hash) (Recovered, bool) {
+	if rc.size == 0 || key == (common.Hash{}) {
+		return Recovered{}, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if e, ok := rc.items[key]; ok {
+		rc.lru.MoveToFront(e)
+		r := e.Value.(*recoverEntry).r
+		r.Pub = common.CopyBytes(r.Pub)
+		return r, true
+	}
+	return Recovered{}, false
+}
+
+// add caches only successfully recovered signatures
+func (rc *Recoverer) add(key common.Hash, r Recovered) {
+	if rc.size == 0 || key == (common.Hash{}) || r.Err != nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if _, ok := rc.items[key]; ok {
+		return
+	}
+	r.Pub = common.CopyBytes(r.Pub)
+	rc.items[key] = rc.lru.PushFront(&recoverEntry{key, r})
+	if rc.lru.Len() > rc.size {
+		e := rc.lru.Back()
+		rc.lru.Remove(e)
+		delete(rc.items, e.Value.(*recoverEntry).key)
+	}
+}
+
+func (rc *Recoverer) recover(s *Signature) (r Recovered) {
+	if r, ok := rc.get(s.Key); ok {
+		return r
+	}
+	r.Pub, r.Err = Ecrecover(s.Hash, s.Sig)
+	if r.Err == nil {
+		r.Address = common.BytesToAddress(Keccak256(r.Pub[1:])[12:])
+	}
+	rc.add(s.Key, r)
+	return
+}
+
+func (rc *Recoverer) slots() chan struct{} {
+	rc.once.Do(func() {
+		rc.helpers = make(chan struct{}, rc.workers()-1)
+	})
+	return rc.helpers
+}
+
+// RecoverBatch recovers public keys and addresses of signatures in parallel,
+// results are in order of signatures, every result has its own error.
+// The caller recovers signatures too, it's helped by free workers only.
+func (rc *Recoverer) RecoverBatch(sigs []Signature) []Recovered {
+	results := make([]Recovered, len(sigs))
+	var next int64 = -1
+	work := func() {
+		for {
+			i := int(atomic.AddInt64(&next, 1))
+			if i >= len(sigs) {
+				return
+			}
+			results[i] = rc.recover(&sigs[i])
+		}
+	}
+
+	var wg sync.WaitGroup
+	slots := rc.slots()
+Helpers:
+	for n := 1; n < len(sigs); n++ {
+		select {
+		case slots <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer func() { <-slots; wg.Done() }()
+				work()
+			}()
+		default:
+			break Helpers
+		}
+	}
+	work()
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func testSignatures(t testing.TB, n int) ([]Signature, []common.Address) {
+	sigs := make([]Signature, n)
+	addrs := make([]common.Address, n)
+	for i := range sigs {
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(i))
+		hash := Keccak256(b[:])
+		sig, err := Sign(hash, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs[i] = Signature{Key: Keccak256Hash(hash), Hash: hash, Sig: sig}
+		addrs[i] = PubkeyToAddress(key.PublicKey)
+	}
+	return sigs, addrs
+}
+
+func TestRecoverBatch(t *testing.T) {
+	sigs, addrs := testSignatures(t, 100)
+	// the signature with invalid recovery id fails alone
+	bad := make([]byte, 65)
+	copy(bad, sigs[10].Sig)
+	bad[64] = 4
+	sigs[10].Sig = bad
+
+	for _, workers := range []int{1, 4} {
+		rc := NewRecoverer(workers, 0)
+		for i, r := range rc.RecoverBatch(sigs) {
+			if i == 10 {
+				if r.Err == nil {
+					t.Errorf("workers %d: invalid signature is recovered", workers)
+				}
+				continue
+			}
+			if r.Err != nil {
+				t.Errorf("workers %d: signature %d: %v", workers, i, r.Err)
+				continue
+			}
+			checkAddr(t, r.Address, addrs[i])
+			pub, _ := Ecrecover(sigs[i].Hash, sigs[i].Sig)
+			if string(pub) != string(r.Pub) {
+				t.Errorf("workers %d: signature %d: public key mismatch", workers, i)
+			}
+		}
+	}
+	if len(NewRecoverer(4, 0).RecoverBatch(nil)) != 0 {
+		t.Error("empty batch has results")
+	}
+}
+
+func TestRecoverBatchCache(t *testing.T) {
+	sigs, addrs := testSignatures(t, 10)
+	rc := NewRecoverer(4, 5)
+	rc.RecoverBatch(sigs)
+	if rc.lru.Len() != 5 || len(rc.items) != 5 {
+		t.Fatalf("wrong cache size %d", rc.lru.Len())
+	}
+
+	// cached results are returned by the key even if the signature is broken now
+	var cached []Signature
+	var cachedAddrs []common.Address
+	for i := range sigs {
+		if _, ok := rc.items[sigs[i].Key]; ok {
+			s := sigs[i]
+			s.Sig = make([]byte, 65)
+			cached = append(cached, s)
+			cachedAddrs = append(cachedAddrs, addrs[i])
+		}
+	}
+	if len(cached) != 5 {
+		t.Fatalf("wrong count of cached signatures %d", len(cached))
+	}
+	for i, r := range rc.RecoverBatch(cached) {
+		if r.Err != nil {
+			t.Errorf("signature %d: %v", i, r.Err)
+			continue
+		}
+		checkAddr(t, r.Address, cachedAddrs[i])
+	}
+
+	// cached public key is not changed by the caller
+	r := rc.RecoverBatch(cached[:1])[0]
+	pub := string(r.Pub)
+	r.Pub[1] ^= 0xff
+	if r = rc.RecoverBatch(cached[:1])[0]; string(r.Pub) != pub {
+		t.Error("cached public key is changed by the caller")
+	}
+
+	// failures are not cached
+	rc = NewRecoverer(1, 5)
+	bad := Signature{Key: sigs[0].Key, Hash: sigs[0].Hash, Sig: make([]byte, 65)}
+	rc.RecoverBatch([]Signature{bad})
+	if rc.lru.Len() != 0 {
+		t.Error("failed recovery is cached")
+	}
+}
+
+func TestRecoverBatchConcurrent(t *testing.T) {
+	sigs, addrs := testSignatures(t, 50)
+	rc := NewRecoverer(3, 0)
+	var wg sync.WaitGroup
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, r := range rc.RecoverBatch(sigs) {
+				if r.Err != nil || r.Address != addrs[i] {
+					t.Errorf("signature %d: %x %v", i, r.Address, r.Err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// concurrent batches share two helpers of their callers
+	if cap(rc.helpers) != 2 || len(rc.helpers) != 0 {
+		t.Errorf("wrong helpers %d of %d", len(rc.helpers), cap(rc.helpers))
+	}
+}
+
+const benchSignatures = 1000
+
+func BenchmarkEcrecoverLoop(b *testing.B) {
+	sigs, _ := testSignatures(b, benchSignatures)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range sigs {
+			if _, err := Ecrecover(sigs[j].Hash, sigs[j].Sig); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkRecoverBatch(b *testing.B) {
+	sigs, _ := testSignatures(b, benchSignatures)
+	rc := NewRecoverer(0, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rc.RecoverBatch(sigs)
+	}
+}
+
+func BenchmarkRecoverBatchCached(b *testing.B) {
+	sigs, _ := testSignatures(b, benchSignatures)
+	rc := NewRecoverer(0, benchSignatures)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rc.RecoverBatch(sigs)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !cgo nocgo
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// The pure Go backend is used when cgo is disabled or the nocgo tag is set,
+// it behaves as libsecp256k1 does: signatures are deterministic (RFC 6979)
+// with low s values, so both backends produce the same bytes
+
+var (
+	errInvalidMsgLen       = errors.New("invalid message length, need 32 bytes")
+	errInvalidSignatureLen = errors.New("invalid signature length")
+	errInvalidRecoveryID   = errors.New("invalid signature recovery id")
+	errInvalidSignature    = errors.New("invalid signature")
+	errInvalidPrivateKey   = errors.New("invalid private key")
+)
+
+// Ecrecover returns the uncompressed public key that created the given signature.
+func Ecrecover(hash, sig []byte) ([]byte, error) {
+	pub, err := SigToPub(hash, sig)
+	if err != nil {
+		return nil, err
+	}
+	return (*btcec.PublicKey)(pub).SerializeUncompressed(), nil
+}
+
+// SigToPub returns the public key that created the given signature.
+func SigToPub(hash, sig []byte) (*ecdsa.PublicKey, error) {
+	if len(hash) != 32 {
+		return nil, errInvalidMsgLen
+	}
+	if len(sig) != 65 {
+		return nil, errInvalidSignatureLen
+	}
+	if sig[64] >= 4 {
+		return nil, errInvalidRecoveryID
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(secp256k1N) >= 0 || s.Cmp(secp256k1N) >= 0 {
+		return nil, errInvalidSignature
+	}
+
+	// btcec expects the recovery id at the beginning as 27 + v
+	btcsig := make([]byte, 65)
+	btcsig[0] = sig[64] + 27
+	copy(btcsig[1:], sig)
+
+	pub, _, err := btcec.RecoverCompact(btcec.S256(), btcsig, hash)
+	if err != nil {
+		return nil, err
+	}
+	return (*ecdsa.PublicKey)(pub), nil
+}
+
+// Sign calculates an ECDSA signature.
+//
+// The produced signature is in the [R || S || V] format where V is 0 or 1.
+func Sign(hash []byte, prv *ecdsa.PrivateKey) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("hash is required to be exactly 32 bytes (%d)", len(hash))
+	}
+	if prv.D.Sign() <= 0 || prv.D.Cmp(secp256k1N) >= 0 {
+		return nil, errInvalidPrivateKey
+	}
+
+	key := (*btcec.PrivateKey)(&ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: btcec.S256(), X: prv.X, Y: prv.Y},
+		D:         prv.D,
+	})
+	sig, err := btcec.SignCompact(btcec.S256(), key, hash, false)
+	if err != nil {
+		return nil, err
+	}
+	// move the recovery id to the end as 0 or 1
+	v := sig[0] - 27
+	copy(sig, sig[1:])
+	sig[64] = v
+	return sig, nil
+}
+
+// S256 returns an instance of the secp256k1 curve.
+func S256() elliptic.Curve {
+	return btcec.S256()
+}
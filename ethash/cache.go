@@ -1,12 +1,12 @@
 package ethash
 
 import (
+	"os"
 	"time"
 	"sync"
 
 	"github.com/sudachen/playground/logger/glog"
 	"github.com/sudachen/playground/logger"
-	"github.com/sudachen/playground/sha3"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -26,9 +26,10 @@ type node struct {
 }
 
 type cache struct {
-	epoch uint64
+	epoch epoch
 	used  time.Time
 	test  bool
+	dir   string // directory of cache files, cache is not stored if empty
 
 	gen sync.Once // ensures cache is only generated once.
 
@@ -36,6 +37,10 @@ type cache struct {
 	nodes []node
 }
 
+// new generates the cache, both the initial fill and RandMemoHash rounds
+// are chains of hashes where every node depends on the previous one,
+// so they can't be batched over lanes of Keccak-512, caches of different
+// epochs are generated in parallel instead
 func (c *cache) new(cacheSize uint64,seed common.Hash) {
 	n := make([]node,cacheSize/NodeSize)
 	count := len(n)
@@ -51,9 +56,7 @@ func (c *cache) new(cacheSize uint64,seed common.Hash) {
 		for i := 0; i < count; i++ {
 			idx := n[i].w[0] % uint32(count)
 			d := n[(count - 1 + i) % count]
-			for w := 0; w < NodeWords; w++ {
-				d.w[w] ^= n[idx].w[w]
-			}
+			xorNode(&d, &n[idx])
 			sha512.h64(&n[i],&d)
 		}
 	}
@@ -64,14 +67,27 @@ func (c *cache) new(cacheSize uint64,seed common.Hash) {
 func (c *cache) generate() {
 	c.gen.Do(func() {
 		started := time.Now()
-		seedHash := makeSeedHash(c.epoch)
-		glog.V(logger.Debug).Infof("Generating cache for epoch %d (%x)", c.epoch, seedHash)
-		size := cachesize(c.epoch * epochLength)
+		seedHash := c.epoch.seed()
+		glog.V(logger.Debug).Infof("Generating cache for epoch %v (%x)", c.epoch, seedHash)
+		size := cachesize(c.epoch.number)
 		if c.test {
 			size = cacheSizeForTesting
 		}
+		if c.dir != "" {
+			if err := c.load(size); err == nil {
+				glog.V(logger.Debug).Infof("Loaded cache for epoch %v, it took %v", c.epoch, time.Since(started))
+				return
+			} else if !os.IsNotExist(err) {
+				glog.V(logger.Warn).Infof("Failed to load cache for epoch %v: %v", c.epoch, err)
+			}
+		}
 		c.new(size,seedHash)
-		glog.V(logger.Debug).Infof("Done generating cache for epoch %d, it took %v", c.epoch, time.Since(started))
+		glog.V(logger.Debug).Infof("Done generating cache for epoch %v, it took %v", c.epoch, time.Since(started))
+		if c.dir != "" {
+			if err := c.store(); err != nil {
+				glog.V(logger.Warn).Infof("Failed to store cache for epoch %v: %v", c.epoch, err)
+			}
+		}
 	})
 }
 
@@ -90,15 +106,20 @@ func (c *cache) dagi(index uint32, r *node, k *keccakf ) {
 }
 
 func (c *cache) compute(fullSize uint64, hash common.Hash, nonce uint64) (ok bool, mixDigest, result common.Hash) {
+	sha512 := new512()
+	return hashimoto(fullSize, hash, nonce, sha512, func(index uint32, r *node) {
+		c.dagi(index, r, sha512)
+	})
+}
+
+// hashimoto computes mix digest and result, lookup returns dataset node
+// which is calculated from cache by light verifier or taken from full dataset
+func hashimoto(fullSize uint64, hash common.Hash, nonce uint64, sha512 *keccakf, lookup func(uint32,*node)) (ok bool, mixDigest, result common.Hash) {
 	numMixes := uint32(fullSize/(MixWords * 4))
 	var s node
 	var mix [MixWords]uint32
 
-	sha512 := new512()
-	copyB32ToNode(hash[:],&s)
-	s.w[8] = uint32(nonce)
-	s.w[9] = uint32(nonce >> 32)
-	sha512.h40(&s,&s)
+	seedNode(hash, nonce, &s, sha512)
 
 	copy(mix[:NodeWords],s.w[:])
 	copy(mix[NodeWords:],s.w[:])
@@ -106,8 +127,8 @@ func (c *cache) compute(fullSize uint64, hash common.Hash, nonce uint64) (ok boo
 	var r1, r2 node
 	for i := uint32(0); i < EthAccess; i++ {
 		p := ((s.w[0] ^ i) * FnvPrime ^ (mix[i%MixWords])) % numMixes
-		c.dagi(p * MixNodes, &r1, sha512)
-		c.dagi(p * MixNodes + 1, &r2, sha512)
+		lookup(p * MixNodes, &r1)
+		lookup(p * MixNodes + 1, &r2)
 		for w := 0; w < NodeWords; w++ {
 			mix[w] = mix[w] * FnvPrime ^ r1.w[w]
 			mix[w+NodeWords] = mix[w+NodeWords] * FnvPrime ^ r2.w[w]
@@ -125,15 +146,22 @@ func (c *cache) compute(fullSize uint64, hash common.Hash, nonce uint64) (ok boo
 	}
 
 	copyNodeToB32(&cmix,mixDigest[:])
-
-	q := make([]byte,64+32)
-	copyNodeToB64(&s,q)
-	copyNodeToB32(&cmix,q[64:])
-	sha256 := sha3.NewKeccak256().(h)
-	sha256.Write(q)
-	copy(result[:],sha256.Out())
+	result = resultHash(&s, mixDigest, sha512)
 
 	ok = true
 
 	return
 }
+
+// seedNode is keccak512 of header hash and nonce
+func seedNode(hash common.Hash, nonce uint64, s *node, sha512 *keccakf) {
+	copyB32ToNode(hash[:],s)
+	s.w[8] = uint32(nonce)
+	s.w[9] = uint32(nonce >> 32)
+	sha512.h40(s,s)
+}
+
+// resultHash is keccak256 of seed node and mix digest
+func resultHash(s *node, mixDigest common.Hash, k *keccakf) common.Hash {
+	return k.h96(s, &mixDigest)
+}
@@ -0,0 +1,68 @@
+package ethash
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	geth "github.com/ethereum/go-ethereum/consensus/ethash"
+)
+
+func TestDagiFNV(t *testing.T) {
+	for k := 0; k < 1000; k++ {
+		var r, p node
+		for i := range r.w {
+			r.w[i] = rand.Uint32()
+			p.w[i] = rand.Uint32()
+		}
+		r1 := r
+		dagiFNV(&r, &p)
+		dagiFNVGeneric(&r1, &p)
+		if r != r1 {
+			t.Fatalf("dagiFNV differs from generic implementation")
+		}
+	}
+}
+
+func BenchmarkDagiFNV(b *testing.B) {
+	var r, p node
+	for i := 0; i < b.N; i++ {
+		dagiFNV(&r, &p)
+	}
+}
+
+func BenchmarkDagiFNVGeneric(b *testing.B) {
+	var r, p node
+	for i := 0; i < b.N; i++ {
+		dagiFNVGeneric(&r, &p)
+	}
+}
+
+// BenchmarkCacheGeneration measures one epoch cache generation
+func BenchmarkCacheGeneration(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		c := &cache{}
+		c.new(cachesize(0), makeSeedHash(0))
+	}
+}
+
+// BenchmarkCacheGenerationParallel measures time per epoch when caches
+// of several epochs are generated at once
+func BenchmarkCacheGenerationParallel(b *testing.B) {
+	var n uint64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			e := atomic.AddUint64(&n, 1) % 16
+			c := &cache{epoch: epoch{number: e}}
+			c.new(cachesize(e), makeSeedHash(e))
+		}
+	})
+}
+
+// BenchmarkGoEthereumCacheGeneration measures go-ethereum ethash cache generation,
+// the cache is generated in memory without the directory as BenchmarkCacheGeneration does
+func BenchmarkGoEthereumCacheGeneration(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		geth.MakeCache(0, "")
+	}
+}
@@ -0,0 +1,112 @@
+package ethash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"unsafe"
+
+	"github.com/sudachen/playground/crypto"
+)
+
+// cacheFileVersion is changed when the layout of cache file is changed,
+// the file is the magic, nodes and keccak256 checksum of nodes
+const cacheFileVersion = 1
+
+const cacheChecksumSize = 32
+
+func bytesOf(nodes []node) (b []byte) {
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	h.Data = uintptr(unsafe.Pointer(&nodes[0]))
+	h.Len = len(nodes) * NodeSize
+	h.Cap = h.Len
+	return
+}
+
+// name of cache file, nodes are stored in the machine byte order,
+// so the file is not portable across architectures
+func (c *cache) name() string {
+	return fmt.Sprintf("cache-R%d-v%d-%s", algorithmRevision, cacheFileVersion, fileSuffix(c.epoch, c.test))
+}
+
+// load reads cache from the file, it fails if file does not exist
+// or it's corrupted
+func (c *cache) load(size uint64) error {
+	fn := filepath.Join(c.dir, c.name())
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if fi, err := f.Stat(); err != nil {
+		return err
+	} else if fi.Size() != int64(size)+dumpHeaderSize+cacheChecksumSize {
+		return fmt.Errorf("cache file %s has wrong size %d", fn, fi.Size())
+	}
+
+	var hdr [dumpHeaderSize]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(hdr[:]) != dumpMagic[0] || binary.LittleEndian.Uint32(hdr[4:]) != dumpMagic[1] {
+		return fmt.Errorf("cache file %s is corrupted", fn)
+	}
+
+	nodes := make([]node, size/NodeSize)
+	b := bytesOf(nodes)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return err
+	}
+	var sum [cacheChecksumSize]byte
+	if _, err := io.ReadFull(f, sum[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum[:], crypto.Keccak256(b)) {
+		return fmt.Errorf("cache file %s has wrong checksum", fn)
+	}
+
+	c.nodes = nodes
+	return nil
+}
+
+// store writes cache into temporary file and renames it when it's done,
+// so concurrent processes never see incomplete file
+func (c *cache) store() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	fn := filepath.Join(c.dir, c.name())
+	tmp := fn + "." + strconv.Itoa(rand.Int())
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	var hdr [dumpHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[:], dumpMagic[0])
+	binary.LittleEndian.PutUint32(hdr[4:], dumpMagic[1])
+	b := bytesOf(c.nodes)
+
+	for _, p := range [][]byte{hdr[:], b, crypto.Keccak256(b)} {
+		if _, err = f.Write(p); err != nil {
+			break
+		}
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -1,10 +1,17 @@
 package ethash
 
-const epochLength uint64 = 30000
 const cacheSizeForTesting uint64 = 1024
 
-func cachesize(block uint64) uint64 {
-	return cacheSizes[block / epochLength]
+// cachesize returns the cache size of the epoch, the table is the fast path
+// for the first epochs, the size is calculated beyond it
+func cachesize(epoch uint64) uint64 {
+	if epoch < uint64(len(cacheSizes)) {
+		return cacheSizes[epoch]
+	}
+	if epoch > maxEpoch {
+		return 0
+	}
+	return calcCacheSize(epoch)
 }
 
 var cacheSizes = [2048]uint64{
@@ -0,0 +1,30 @@
+#include "textflag.h"
+
+// FNV multiplies four words by the prime in X7 and xors them with
+// the parent words, SSE2 has no 32-bit multiplication, so even and odd
+// words are multiplied by PMULULQ separately and shuffled back
+#define FNV(off) \
+	MOVOU off(DI), X0; \
+	MOVOU X0, X1; \
+	PMULULQ X7, X0; \
+	PSRLQ $32, X1; \
+	PMULULQ X7, X1; \
+	PSHUFL $0x08, X0, X0; \
+	PSHUFL $0x08, X1, X1; \
+	PUNPCKLLQ X1, X0; \
+	MOVOU off(SI), X2; \
+	PXOR X2, X0; \
+	MOVOU X0, off(DI)
+
+// func dagiFNV(r, p *node)
+TEXT ·dagiFNV(SB), NOSPLIT, $0-16
+	MOVQ r+0(FP), DI
+	MOVQ p+8(FP), SI
+	MOVQ $0x01000193, AX
+	MOVQ AX, X7
+	PSHUFL $0, X7, X7
+	FNV(0)
+	FNV(16)
+	FNV(32)
+	FNV(48)
+	RET
@@ -0,0 +1,217 @@
+package ethash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/sudachen/playground/logger"
+	"github.com/sudachen/playground/logger/glog"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// algorithmRevision is the ethash revision, it's a part of dataset file name
+const algorithmRevision = 23
+
+// dumpMagic starts the dataset file, it's written after generation
+// so partially generated file is never used
+var dumpMagic = [2]uint32{0xbaddcafe, 0xfee1dead}
+
+const dumpHeaderSize = 8
+
+type dataset struct {
+	epoch epoch
+	test  bool
+
+	gen sync.Once // ensures dataset is only generated once.
+
+	mem   []byte // mapped file, nil if dataset is in memory
+	nodes []node
+}
+
+func (d *dataset) size() uint64 {
+	if d.test {
+		return dagSizeForTesting
+	}
+	return datasize(d.epoch.number)
+}
+
+// name of dataset file, nodes are stored in the machine byte order,
+// so the file is not portable across architectures
+func (d *dataset) name() string {
+	return fmt.Sprintf("full-R%d-%s", algorithmRevision, fileSuffix(d.epoch, d.test))
+}
+
+// fileSuffix identifies the epoch in file names, ECIP-1099 epochs share
+// the seed with the original ones, so the length is added to their names
+func fileSuffix(e epoch, test bool) string {
+	seed := e.seed()
+	s := fmt.Sprintf("%x", seed[:8])
+	if e.len() != epochLength {
+		s += fmt.Sprintf("-e%d", e.len())
+	}
+	if test {
+		s += ".test"
+	}
+	return s
+}
+
+func nodesOf(mem []byte) (nodes []node) {
+	n := (len(mem) - dumpHeaderSize) / NodeSize
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&nodes))
+	h.Data = uintptr(unsafe.Pointer(&mem[dumpHeaderSize]))
+	h.Len = n
+	h.Cap = n
+	return
+}
+
+// generate calculates dataset from the cache, dataset is memory mapped
+// if dir is specified, so existing file is reused by the next run
+func (d *dataset) generate(dir string, threads int, getCache func() *cache) {
+	d.gen.Do(func() {
+		if dir != "" {
+			fn := filepath.Join(dir, d.name())
+			if err := d.mmap(fn); err == nil {
+				glog.V(logger.Debug).Infof("Loaded DAG for epoch %v from %s", d.epoch, fn)
+				return
+			}
+		}
+
+		started := time.Now()
+		glog.V(logger.Debug).Infof("Generating DAG for epoch %v", d.epoch)
+		c := getCache()
+		if dir != "" {
+			if err := d.dump(dir, threads, c); err != nil {
+				glog.V(logger.Warn).Infof("Failed to map DAG for epoch %v, it's generated in memory: %v", d.epoch, err)
+			}
+		}
+		if d.nodes == nil {
+			d.nodes = make([]node, d.size()/NodeSize)
+			d.fill(c, threads)
+		}
+		glog.V(logger.Debug).Infof("Done generating DAG for epoch %v, it took %v", d.epoch, time.Since(started))
+	})
+}
+
+// fill calculates dataset nodes in parallel, every thread has its own range
+func (d *dataset) fill(c *cache, threads int) {
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	n := uint32(len(d.nodes))
+	batch := (n + uint32(threads) - 1) / uint32(threads)
+
+	var wg sync.WaitGroup
+	for first := uint32(0); first < n; first += batch {
+		last := first + batch
+		if last > n {
+			last = n
+		}
+		wg.Add(1)
+		go func(first, last uint32) {
+			defer wg.Done()
+			sha512 := new512()
+			for i := first; i < last; i++ {
+				c.dagi(i, &d.nodes[i], sha512)
+			}
+		}(first, last)
+	}
+	wg.Wait()
+}
+
+// mmap maps existing dataset file, it fails if file is incomplete
+func (d *dataset) mmap(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size := int(d.size()) + dumpHeaderSize
+	if fi, err := f.Stat(); err != nil {
+		return err
+	} else if fi.Size() != int64(size) {
+		return fmt.Errorf("dataset file %s has wrong size %d", fn, fi.Size())
+	}
+
+	mem, err := mmapFile(f, size, false)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(mem) != dumpMagic[0] || binary.LittleEndian.Uint32(mem[4:]) != dumpMagic[1] {
+		munmapFile(mem)
+		return fmt.Errorf("dataset file %s is corrupted", fn)
+	}
+
+	d.mem = mem
+	d.nodes = nodesOf(mem)
+	runtime.SetFinalizer(d, (*dataset).release)
+	return nil
+}
+
+// dump generates dataset into temporary mapped file and renames it when it's done
+func (d *dataset) dump(dir string, threads int, c *cache) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, d.name())
+	tmp := fn + "." + strconv.Itoa(rand.Int())
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	size := int(d.size()) + dumpHeaderSize
+	if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	mem, err := mmapFile(f, size, true)
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	d.nodes = nodesOf(mem)
+	d.fill(c, threads)
+	binary.LittleEndian.PutUint32(mem, dumpMagic[0])
+	binary.LittleEndian.PutUint32(mem[4:], dumpMagic[1])
+	d.nodes = nil
+
+	if err := munmapFile(mem); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return d.mmap(fn)
+}
+
+func (d *dataset) release() {
+	if d.mem != nil {
+		munmapFile(d.mem)
+		d.mem = nil
+		d.nodes = nil
+	}
+}
+
+func (d *dataset) lookup(index uint32, r *node) {
+	*r = d.nodes[index]
+}
+
+func (d *dataset) compute(hash common.Hash, nonce uint64, sha512 *keccakf) (ok bool, mixDigest, result common.Hash) {
+	return hashimoto(uint64(len(d.nodes))*NodeSize, hash, nonce, sha512, d.lookup)
+}
@@ -2,8 +2,16 @@ package ethash
 
 const dagSizeForTesting uint64 = 1024 * 32
 
-func datasize(block uint64) uint64 {
-	return dagSizes[block / epochLength]
+// datasize returns the dataset size of the epoch, the table is the fast path
+// for the first epochs, the size is calculated beyond it
+func datasize(epoch uint64) uint64 {
+	if epoch < uint64(len(dagSizes)) {
+		return dagSizes[epoch]
+	}
+	if epoch > maxEpoch {
+		return 0
+	}
+	return calcDatasetSize(epoch)
 }
 
 var dagSizes = [2048]uint64{
@@ -0,0 +1,160 @@
+package ethash
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var errInvalidPoW = errors.New("invalid proof-of-work")
+
+// Engine adapts Light to go-ethereum consensus.Engine. Header rules and
+// block rewards are of the wrapped engine, only seals are verified by Light
+// instead of the wrapped one.
+type Engine struct {
+	consensus.Engine
+	Light   *Light
+	Workers int // Count of threads verifying seals, NumCPU by default
+}
+
+// NewEngine wraps the engine, a new Light is created if light is nil
+func NewEngine(engine consensus.Engine, light *Light) *Engine {
+	if light == nil {
+		light = &Light{}
+	}
+	return &Engine{Engine: engine, Light: light}
+}
+
+func (e *Engine) workers() int {
+	if e.Workers > 0 {
+		return e.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// VerifySeal checks the nonce and mix digest of the header, the head
+// of the chain limits epochs of verified headers if the chain is known
+func (e *Engine) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if header.Difficulty.Sign() <= 0 {
+		return fmt.Errorf("non-positive difficulty")
+	}
+	if chain != nil {
+		if head := chain.CurrentHeader(); head != nil {
+			e.Light.SetHead(head.Number.Uint64())
+		}
+	}
+	if !e.Light.Verify(types.NewBlockWithHeader(header)) {
+		return errInvalidPoW
+	}
+	return nil
+}
+
+// VerifyHeader checks the header by rules of the wrapped engine and verifies
+// the seal if it's requested
+func (e *Engine) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	if err := e.Engine.VerifyHeader(chain, header, false); err != nil {
+		return err
+	}
+	if !seal {
+		return nil
+	}
+	number := header.Number.Uint64()
+	if chain.GetHeader(header.Hash(), number) != nil {
+		return nil
+	}
+	// the parent accepted by the chain is the known head, the wrapped
+	// engine may not check it, so the head is not moved by unknown parents
+	if number > 0 && chain.GetHeader(header.ParentHash, number-1) != nil {
+		e.Light.SetHead(number - 1)
+	}
+	return e.VerifySeal(nil, header)
+}
+
+// VerifyHeaders checks headers by rules of the wrapped engine and verifies
+// seals by the pool of workers, so seals of different epochs are verified
+// in parallel. Results are sent in order of headers, the verification is
+// stopped when the abort channel is closed.
+func (e *Engine) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+	if len(headers) == 0 {
+		return abort, results
+	}
+
+	// the wrapped engine verifies header rules only
+	rulesAbort, rules := e.Engine.VerifyHeaders(chain, headers, make([]bool, len(headers)))
+
+	// the parent of the batch is accepted by the chain, so it's the known head
+	if first := headers[0]; first.Number.Sign() > 0 && chain.GetHeader(first.ParentHash, first.Number.Uint64()-1) != nil {
+		e.Light.SetHead(first.Number.Uint64() - 1)
+	}
+
+	workers := e.workers()
+	if len(headers) < workers {
+		workers = len(headers)
+	}
+
+	var (
+		inputs = make(chan int)
+		done   = make(chan int, workers)
+		errs   = make([]error, len(headers))
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range inputs {
+				errs[index] = e.verifySealAt(chain, headers, seals, index)
+				done <- index
+			}
+		}()
+	}
+
+	go func() {
+		defer close(inputs)
+		defer close(rulesAbort)
+		var (
+			in, out = 0, 0
+			checked = make([]bool, len(headers))
+			next    = inputs
+		)
+		for {
+			select {
+			case next <- in:
+				if in++; in == len(headers) {
+					// all headers are queued, stop sending
+					next = nil
+				}
+			case index := <-done:
+				for checked[index] = true; checked[out]; out++ {
+					select {
+					case err := <-rules:
+						if err == nil {
+							err = errs[out]
+						}
+						results <- err
+					case <-abort:
+						return
+					}
+					if out == len(headers)-1 {
+						return
+					}
+				}
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifySealAt verifies the seal of the header if it's requested
+// and the header is not known
+func (e *Engine) verifySealAt(chain consensus.ChainReader, headers []*types.Header, seals []bool, index int) error {
+	header := headers[index]
+	if !seals[index] || chain.GetHeader(header.Hash(), header.Number.Uint64()) != nil {
+		return nil
+	}
+	return e.VerifySeal(nil, header)
+}
@@ -0,0 +1,196 @@
+package ethash
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type testChain map[common.Hash]*types.Header
+
+func (c testChain) Config() *params.ChainConfig                     { return params.TestChainConfig }
+func (c testChain) CurrentHeader() *types.Header                    { return nil }
+func (c testChain) GetHeader(h common.Hash, n uint64) *types.Header { return c[h] }
+func (c testChain) GetHeaderByNumber(n uint64) *types.Header        { return nil }
+func (c testChain) GetHeaderByHash(h common.Hash) *types.Header     { return c[h] }
+func (c testChain) GetBlock(h common.Hash, n uint64) *types.Block   { return nil }
+
+var errTestTimestamp = errors.New("timestamp older than parent")
+
+// testRules is the wrapped engine checking the parent and the timestamp,
+// seals must be verified by Engine only
+type testRules struct {
+	consensus.Engine
+}
+
+func (r testRules) verify(header, parent *types.Header, seal bool) error {
+	if seal {
+		panic("seal is verified by the wrapped engine")
+	}
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	if header.Time.Cmp(parent.Time) <= 0 {
+		return errTestTimestamp
+	}
+	return nil
+}
+
+func (r testRules) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return r.verify(header, chain.GetHeader(header.ParentHash, header.Number.Uint64()-1), seal)
+}
+
+func (r testRules) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort, results := make(chan struct{}), make(chan error, len(headers))
+	go func() {
+		for i, h := range headers {
+			parent := chain.GetHeader(h.ParentHash, h.Number.Uint64()-1)
+			if i > 0 && headers[i-1].Hash() == h.ParentHash {
+				parent = headers[i-1]
+			}
+			select {
+			case results <- r.verify(h, parent, seals[i]):
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
+
+// sealedHeaders makes the chain of headers with valid seals
+func sealedHeaders(eth *Ethash, parent *types.Header, n int) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		h := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number, common.Big1),
+			Time:       new(big.Int).Add(parent.Time, common.Big1),
+			Difficulty: big.NewInt(100),
+			GasLimit:   params.GenesisGasLimit,
+		}
+		nonce, md := eth.Search(types.NewBlockWithHeader(h), nil, 0)
+		h.Nonce = types.EncodeNonce(nonce)
+		h.MixDigest = common.BytesToHash(md)
+		headers[i] = h
+		parent = h
+	}
+	return headers
+}
+
+func newTestEngine(t *testing.T) (*Engine, *Ethash, *types.Header) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := &types.Header{
+		Number:     new(big.Int).SetUint64(epochLength - 10),
+		Time:       big.NewInt(1),
+		Difficulty: big.NewInt(100),
+	}
+	e := NewEngine(testRules{}, eth.Light)
+	e.Workers = 4
+	return e, eth, genesis
+}
+
+func TestEngineVerifyHeader(t *testing.T) {
+	e, eth, genesis := newTestEngine(t)
+	defer os.RemoveAll(eth.Full.Dir)
+	chain := testChain{genesis.Hash(): genesis}
+
+	h := sealedHeaders(eth, genesis, 1)[0]
+	if err := e.VerifyHeader(chain, h, true); err != nil {
+		t.Fatal(err)
+	}
+	h.Nonce = types.EncodeNonce(h.Nonce.Uint64() + 1)
+	if err := e.VerifyHeader(chain, h, true); err != errInvalidPoW {
+		t.Fatalf("header with wrong nonce: %v", err)
+	}
+	if err := e.VerifyHeader(chain, h, false); err != nil {
+		t.Fatalf("seal is verified: %v", err)
+	}
+	h.Time = genesis.Time
+	if err := e.VerifyHeader(chain, h, true); err != errTestTimestamp {
+		t.Fatalf("rules of the wrapped engine are not verified: %v", err)
+	}
+	h.ParentHash = common.Hash{}
+	if err := e.VerifyHeader(chain, h, false); err != consensus.ErrUnknownAncestor {
+		t.Fatalf("header with unknown parent: %v", err)
+	}
+}
+
+// noAncestorRules is the wrapped engine which does not check the parent
+type noAncestorRules struct {
+	testRules
+}
+
+func (r noAncestorRules) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return nil
+}
+
+func TestEngineVerifyHeaderUnknownParent(t *testing.T) {
+	e := NewEngine(noAncestorRules{}, &Light{test: true})
+	// any mix digest passes quick verification with the minimal difficulty
+	h := &types.Header{
+		ParentHash: common.HexToHash("0x01"),
+		Number:     new(big.Int).SetUint64(epochLength * (defaultMaxEpochsAhead + 1)),
+		Difficulty: big.NewInt(1),
+	}
+	if err := e.VerifyHeader(testChain{}, h, true); err != errInvalidPoW {
+		t.Fatalf("header far ahead with unknown parent: %v", err)
+	}
+	if e.Light.head != 0 || len(e.Light.caches) != 0 || e.Light.future != nil {
+		t.Errorf("head %d is set by the unknown parent", e.Light.head)
+	}
+}
+
+func TestEngineVerifyHeaders(t *testing.T) {
+	e, eth, genesis := newTestEngine(t)
+	defer os.RemoveAll(eth.Full.Dir)
+	chain := testChain{genesis.Hash(): genesis}
+
+	// headers are across the epoch boundary
+	headers := sealedHeaders(eth, genesis, 20)
+	seals := make([]bool, len(headers))
+	for i := range seals {
+		seals[i] = true
+	}
+	_, results := e.VerifyHeaders(chain, headers, seals)
+	for i := range headers {
+		if err := <-results; err != nil {
+			t.Fatalf("header %d: %v", i, err)
+		}
+	}
+
+	// the next header is not linked to the header with wrong nonce
+	headers[7].Nonce = types.EncodeNonce(headers[7].Nonce.Uint64() + 1)
+	_, results = e.VerifyHeaders(chain, headers, seals)
+	for i := range headers {
+		err := <-results
+		switch {
+		case i == 7 && err != errInvalidPoW:
+			t.Errorf("header %d with wrong nonce: %v", i, err)
+		case i == 8 && err != consensus.ErrUnknownAncestor:
+			t.Errorf("header %d with unknown parent: %v", i, err)
+		case i != 7 && i != 8 && err != nil:
+			t.Errorf("header %d: %v", i, err)
+		}
+	}
+
+	// errors of the wrapped engine are sent before errors of seals
+	headers = sealedHeaders(eth, genesis, 3)
+	headers[2].Time = headers[1].Time
+	headers[2].Nonce = types.EncodeNonce(headers[2].Nonce.Uint64() + 1)
+	_, results = e.VerifyHeaders(chain, headers, seals[:3])
+	for i := range headers {
+		if err := <-results; (i == 2) != (err == errTestTimestamp) || i != 2 && err != nil {
+			t.Errorf("header %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ethash
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const epochLength uint64 = 30000
+
+// ECIP1099Block is the Ethereum Classic mainnet block since which
+// the epoch length is doubled by ECIP-1099
+const ECIP1099Block uint64 = 11700000
+
+// maxEpoch limits epochs of verified blocks whatever the known head is,
+// the dataset is 512GB there, so sizes are calculated without overflow
+// and seed hashes without long loops
+const maxEpoch uint64 = 1 << 16
+
+// epoch identifies caches and datasets, the same number means different
+// epochs before and after ECIP-1099, so the length is a part of the epoch
+type epoch struct {
+	number uint64
+	length uint64 // epochLength if zero
+}
+
+// epochOf returns the epoch of the block, the epoch length is doubled
+// since the ecip1099 block if it's not zero
+func epochOf(blockNum, ecip1099 uint64) epoch {
+	length := epochLength
+	if ecip1099 != 0 && blockNum >= ecip1099 {
+		length *= 2
+	}
+	return epoch{blockNum / length, length}
+}
+
+func (e epoch) len() uint64 {
+	if e.length == 0 {
+		return epochLength
+	}
+	return e.length
+}
+
+// first returns the first block of the epoch
+func (e epoch) first() uint64 {
+	return e.number * e.len()
+}
+
+// valid returns false if the epoch is beyond maxEpoch
+func (e epoch) valid() bool {
+	return e.number <= maxEpoch
+}
+
+// seed returns the seed hash of the epoch, seeds are counted in epochs
+// of the original length up to the first block of the epoch, so blocks of
+// the second half of the doubled epoch have the seed of the first half
+func (e epoch) seed() common.Hash {
+	return makeSeedHash(e.first() / epochLength)
+}
+
+func (e epoch) String() string {
+	if e.len() == epochLength {
+		return fmt.Sprint(e.number)
+	}
+	return fmt.Sprintf("%d/%d", e.number, e.len())
+}
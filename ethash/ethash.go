@@ -1,32 +1,45 @@
 package ethash
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/sudachen/playground/crypto"
 	"github.com/ethereum/go-ethereum/common"
-
-	ethh "github.com/ethereum/ethash"
 )
 
 type Ethash struct {
 	*Light
-	*ethh.Full
+	*Full
 }
 
 // New creates an instance of the proof of work.
 func New() *Ethash {
-	full := &ethh.Full{}
+	light := &Light{}
+	full := &Full{light: light}
+	full.Turbo(true)
+	return &Ethash{light, full}
+}
+
+// NewWithDir creates an instance of the proof of work which stores caches
+// and datasets in the directory, so they are reused by the next runs.
+func NewWithDir(dir string) *Ethash {
+	light := &Light{Dir: dir}
+	full := &Full{Dir: dir, light: light}
 	full.Turbo(true)
-	return &Ethash{&Light{}, full}
+	return &Ethash{light, full}
 }
 
+// NewForTesting creates an instance with small cache and dataset,
+// datasets are stored in the temporary directory Full.Dir
 func NewForTesting() (*Ethash, error) {
-	e, err := ethh.NewForTesting()
+	dir, err := ioutil.TempDir("", "ethash-test")
 	if err != nil {
 		return nil, err
 	}
-	return &Ethash{&Light{test: true}, e.Full }, nil
+	light := &Light{test: true}
+	return &Ethash{light, &Full{Dir: dir, test: true, light: light}}, nil
 }
 
 var sharedLight = &Light{}
@@ -34,16 +47,23 @@ var sharedLight = &Light{}
 // NewShared creates an instance of the proof of work., where a single instance
 // of the Light cache is shared across all instances created with NewShared.
 func NewShared() *Ethash {
-	full := &ethh.Full{}
+	full := &Full{light: sharedLight}
 	full.Turbo(true)
 	return &Ethash{sharedLight, full}
 }
 
-func GetSeedHash(blockNum uint64) ([]byte, error) {
-	if blockNum >= epochLength*2048 {
-		return nil, fmt.Errorf("block number too high, limit is %d", epochLength*2048)
+func epochError(blockNum uint64) error {
+	return fmt.Errorf("block %d is beyond epoch %d", blockNum, maxEpoch)
+}
+
+// GetSeedHash returns the seed hash of the block epoch, the epoch length is doubled
+// since ecip1099 block if it's not zero, so the seed is the same over the doubled epoch.
+func GetSeedHash(blockNum, ecip1099 uint64) ([]byte, error) {
+	e := epochOf(blockNum, ecip1099)
+	if !e.valid() {
+		return nil, epochError(blockNum)
 	}
-	sh := makeSeedHash(blockNum / epochLength)
+	sh := e.seed()
 	return sh[:], nil
 }
 
@@ -53,3 +73,34 @@ func makeSeedHash(epoch uint64) (sh common.Hash) {
 	}
 	return sh
 }
+
+// CacheSize returns size of the verification cache for the block,
+// the epoch length is doubled since ecip1099 block if it's not zero,
+// it's zero if the block is beyond the maximal epoch.
+func CacheSize(blockNum, ecip1099 uint64) uint64 {
+	return cachesize(epochOf(blockNum, ecip1099).number)
+}
+
+// DatasetSize returns size of the full dataset for the block,
+// the epoch length is doubled since ecip1099 block if it's not zero,
+// it's zero if the block is beyond the maximal epoch.
+func DatasetSize(blockNum, ecip1099 uint64) uint64 {
+	return datasize(epochOf(blockNum, ecip1099).number)
+}
+
+// MakeCache generates the verification cache for the block, nodes are
+// encoded as little-endian words, so it can be compared with other implementations.
+func MakeCache(blockNum, ecip1099 uint64) ([]byte, error) {
+	c := &cache{epoch: epochOf(blockNum, ecip1099)}
+	if !c.epoch.valid() {
+		return nil, epochError(blockNum)
+	}
+	c.generate()
+	b := make([]byte, len(c.nodes)*NodeSize)
+	for i := range c.nodes {
+		for w, v := range c.nodes[i].w {
+			binary.LittleEndian.PutUint32(b[i*NodeSize+w*4:], v)
+		}
+	}
+	return b, nil
+}
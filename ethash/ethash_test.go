@@ -194,14 +194,14 @@ func TestEthashSearchAcrossEpoch(t *testing.T) {
 }
 
 func TestGetSeedHash(t *testing.T) {
-	seed0, err := GetSeedHash(0)
+	seed0, err := GetSeedHash(0, 0)
 	if err != nil {
 		t.Errorf("Failed to get seedHash for block 0: %v", err)
 	}
 	if bytes.Compare(seed0, make([]byte, 32)) != 0 {
 		log.Printf("seedHash for block 0 should be 0s, was: %v\n", seed0)
 	}
-	seed1, err := GetSeedHash(30000)
+	seed1, err := GetSeedHash(30000, 0)
 	if err != nil {
 		t.Error(err)
 	}
@@ -219,3 +219,143 @@ func TestGetSeedHash(t *testing.T) {
 	}
 
 }
+
+func TestQuickVerify(t *testing.T) {
+	maxUint256 := new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
+	for i, block := range validBlocks {
+		target := new(big.Int).Div(maxUint256, block.difficulty)
+		if !quickVerify(block.hashNoNonce, block.nonce, block.mixDigest, target) {
+			t.Errorf("block %d (%x) did not pass quick verification.", i, block.hashNoNonce[:6])
+		}
+	}
+}
+
+func TestEthashVerifyRejectsWithoutCache(t *testing.T) {
+	light := &Light{test: true}
+	block := &testBlock{number: 22, difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}
+	rand.Read(block.hashNoNonce[:])
+	rand.Read(block.mixDigest[:])
+	if light.Verify(block) {
+		t.Fatal("block with random mix digest is verified")
+	}
+	if len(light.caches) != 0 || light.future != nil {
+		t.Error("cache is generated for block failed quick verification")
+	}
+}
+
+func TestEthashVerifyRejectsFarFutureEpoch(t *testing.T) {
+	light := &Light{test: true}
+	light.SetHead(100)
+	// any mix digest passes quick verification with the minimal difficulty
+	block := &testBlock{number: epochLength * (defaultMaxEpochsAhead + 1), difficulty: big.NewInt(1)}
+	rand.Read(block.hashNoNonce[:])
+	rand.Read(block.mixDigest[:])
+	if light.Verify(block) {
+		t.Fatal("block far ahead of the head is verified")
+	}
+	if len(light.caches) != 0 || light.future != nil {
+		t.Error("cache is generated for block far ahead of the head")
+	}
+}
+
+func TestEthashVerifyRejectsFarFutureEpochWithoutHead(t *testing.T) {
+	light := &Light{test: true}
+	block := &testBlock{number: epochLength * (defaultMaxEpochsAhead + 1), difficulty: big.NewInt(1)}
+	rand.Read(block.hashNoNonce[:])
+	rand.Read(block.mixDigest[:])
+	if light.Verify(block) {
+		t.Fatal("block far ahead of genesis is verified")
+	}
+	if len(light.caches) != 0 || light.future != nil {
+		t.Error("cache is generated for block far ahead of genesis")
+	}
+}
+
+func TestEthashVerifyRejectsBeyondMaxEpoch(t *testing.T) {
+	light := &Light{test: true}
+	number := epochLength * (maxEpoch + 1)
+	light.SetHead(number)
+	block := &testBlock{number: number, difficulty: big.NewInt(1)}
+	rand.Read(block.hashNoNonce[:])
+	rand.Read(block.mixDigest[:])
+	if light.Verify(block) {
+		t.Fatal("block beyond the maximal epoch is verified")
+	}
+	if len(light.caches) != 0 || light.future != nil {
+		t.Error("cache is generated for block beyond the maximal epoch")
+	}
+	if _, err := GetSeedHash(number, 0); err == nil {
+		t.Error("seed hash is calculated beyond the maximal epoch")
+	}
+	if _, err := MakeCache(number, 0); err == nil {
+		t.Error("cache is generated beyond the maximal epoch")
+	}
+	if CacheSize(number, 0) != 0 || DatasetSize(number, 0) != 0 {
+		t.Error("sizes are calculated beyond the maximal epoch")
+	}
+	if CacheSize(number-1, 0) == 0 || DatasetSize(number-1, 0) == 0 {
+		t.Error("sizes of the maximal epoch are not calculated")
+	}
+}
+
+func TestEthashVerifyDoesNotAdvanceHead(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{number: epochLength + 1, difficulty: big.NewInt(10)}
+	rand.Read(block.hashNoNonce[:])
+	nonce, md := eth.Search(block, nil, 0)
+	block.nonce = nonce
+	block.mixDigest = common.BytesToHash(md)
+	if !eth.Verify(block) {
+		t.Fatal("block could not be verified")
+	}
+	if eth.Light.head != 0 {
+		t.Errorf("head is advanced to %d by verification", eth.Light.head)
+	}
+}
+
+func TestEthashVerifyBeyondSizeTables(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{number: epochLength*2048 + 1, difficulty: big.NewInt(10)}
+	eth.SetHead(block.number)
+	rand.Read(block.hashNoNonce[:])
+	nonce, md := eth.Search(block, nil, 0)
+	block.nonce = nonce
+	block.mixDigest = common.BytesToHash(md)
+	if !eth.Verify(block) {
+		t.Fatal("block beyond size tables could not be verified")
+	}
+}
+
+func TestEthashSearchAcrossECIP1099(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	eth.ECIP1099Block = epochLength * 4
+	eth.SetHead(eth.ECIP1099Block)
+
+	for i := eth.ECIP1099Block - 20; i < eth.ECIP1099Block+20; i++ {
+		block := &testBlock{number: i, difficulty: big.NewInt(90)}
+		rand.Read(block.hashNoNonce[:])
+		nonce, md := eth.Search(block, nil, 0)
+		block.nonce = nonce
+		block.mixDigest = common.BytesToHash(md)
+		if !eth.Verify(block) {
+			t.Fatalf("block %d could not be verified", i)
+		}
+	}
+	if _, ok := eth.Light.caches[epoch{2, epochLength * 2}]; !ok {
+		t.Error("cache of the doubled epoch is not used")
+	}
+}
@@ -0,0 +1,23 @@
+package ethash
+
+import "unsafe"
+
+// dagiFNVGeneric is FNV mixing of the dataset node with its parent,
+// it's used on platforms without assembly and as the reference in tests
+func dagiFNVGeneric(r, p *node) {
+	for w := 0; w < NodeWords; w++ {
+		r.w[w] = r.w[w]*FnvPrime ^ p.w[w]
+	}
+}
+
+type nodeQ [NodeSize / 8]uint64
+
+// xorNode xors cache nodes by 64-bit words, it's half of the word loop,
+// the time of RandMemoHash rounds is dominated by Keccak-512 anyway
+func xorNode(d, s *node) {
+	dq := (*nodeQ)(unsafe.Pointer(d))
+	sq := (*nodeQ)(unsafe.Pointer(s))
+	for i := range dq {
+		dq[i] ^= sq[i]
+	}
+}
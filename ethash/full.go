@@ -0,0 +1,103 @@
+package ethash
+
+import (
+	"math/big"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// Full implements the Search half of the proof of work. It keeps the full
+// dataset of the current epoch in memory or maps it from the file in Dir.
+type Full struct {
+	Dir     string // Directory of dataset files, dataset is kept in memory if empty
+	Threads int    // Count of threads generating dataset, NumCPU by default
+
+	test  bool   // If set, use a smaller dataset size
+	light *Light // Caches used to generate datasets
+
+	mu      sync.Mutex // Protects the current dataset
+	current *dataset   // Dataset of the last searched epoch
+
+	turbo    bool
+	hashRate int64
+}
+
+func (f *Full) threads() int {
+	if f.Threads > 0 {
+		return f.Threads
+	}
+	return runtime.NumCPU()
+}
+
+// getDataset returns the dataset of the block, epochs are the same as
+// the epochs of the Light caches
+func (f *Full) getDataset(blockNum uint64) *dataset {
+	f.mu.Lock()
+	if f.light == nil {
+		f.light = &Light{test: f.test}
+	}
+	e := f.light.epochOf(blockNum)
+	d := f.current
+	if d == nil || d.epoch != e {
+		// the previous dataset is released when searches using it are finished
+		d = &dataset{epoch: e, test: f.test}
+		f.current = d
+	}
+	f.mu.Unlock()
+
+	d.generate(f.Dir, f.threads(), func() *cache { return f.light.getCache(blockNum) })
+	return d
+}
+
+// Search finds the nonce and mix digest satisfying the block difficulty,
+// it returns zero nonce and nil digest if it's stopped
+func (f *Full) Search(block pow.Block, stop <-chan struct{}, index int) (nonce uint64, mixDigest []byte) {
+	d := f.getDataset(block.NumberU64())
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	hash := block.HashNoNonce()
+	target := new(big.Int).Div(maxUint256, block.Difficulty())
+	sha512 := new512()
+
+	i := int64(0)
+	start := time.Now().UnixNano()
+	previousHashrate := int64(0)
+	nonce = uint64(r.Int63())
+
+	for {
+		select {
+		case <-stop:
+			atomic.AddInt64(&f.hashRate, -previousHashrate)
+			return 0, nil
+		default:
+			i++
+			elapsed := time.Now().UnixNano() - start
+			hashes := ((float64(1e9) / float64(elapsed)) * float64(i)) / 1000
+			hashrateDiff := int64(hashes) - previousHashrate
+			previousHashrate = int64(hashes)
+			atomic.AddInt64(&f.hashRate, hashrateDiff)
+
+			ok, digest, result := d.compute(hash, nonce, sha512)
+			if ok && result.Big().Cmp(target) <= 0 {
+				atomic.AddInt64(&f.hashRate, -previousHashrate)
+				return nonce, digest.Bytes()
+			}
+			nonce++
+		}
+		if !f.turbo {
+			time.Sleep(20 * time.Microsecond)
+		}
+	}
+}
+
+func (f *Full) GetHashrate() int64 {
+	return atomic.LoadInt64(&f.hashRate)
+}
+
+func (f *Full) Turbo(on bool) {
+	f.turbo = on
+}
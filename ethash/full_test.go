@@ -0,0 +1,101 @@
+package ethash
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFullMatchesLight(t *testing.T) {
+	light := &Light{test: true}
+	full := &Full{test: true, light: light, Threads: 3}
+
+	for _, number := range []uint64{0, epochLength + 1} {
+		d := full.getDataset(number)
+		c := light.getCache(number)
+		var hash common.Hash
+		rand.Read(hash[:])
+		for nonce := uint64(0); nonce < 10; nonce++ {
+			_, md1, r1 := d.compute(hash, nonce, new512())
+			_, md2, r2 := c.compute(dagSizeForTesting, hash, nonce)
+			if md1 != md2 || r1 != r2 {
+				t.Fatalf("full and light results differ for block %d nonce %d", number, nonce)
+			}
+		}
+	}
+}
+
+func TestFullDatasetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	full := &Full{Dir: dir, test: true}
+	d1 := full.getDataset(0)
+	if d1.mem == nil {
+		t.Skip("dataset is not memory mapped on this platform")
+	}
+	if _, err := os.Stat(filepath.Join(dir, d1.name())); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next instance loads dataset from the file
+	d2 := (&Full{Dir: dir, test: true}).getDataset(0)
+	if d2.mem == nil || len(d2.nodes) != len(d1.nodes) {
+		t.Fatal("dataset is not loaded from the file")
+	}
+	for i := range d1.nodes {
+		if d1.nodes[i] != d2.nodes[i] {
+			t.Fatalf("dataset node %d differs", i)
+		}
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c1 := &cache{epoch: epoch{number: 1}, test: true, dir: dir}
+	c1.generate()
+	fn := filepath.Join(dir, c1.name())
+	if _, err := os.Stat(fn); err != nil {
+		t.Fatal(err)
+	}
+
+	c2 := &cache{epoch: epoch{number: 1}, test: true, dir: dir}
+	if err := c2.load(cacheSizeForTesting); err != nil {
+		t.Fatal(err)
+	}
+	for i := range c1.nodes {
+		if c1.nodes[i] != c2.nodes[i] {
+			t.Fatalf("cache node %d differs", i)
+		}
+	}
+
+	// corrupted file is not loaded and it's regenerated
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[dumpHeaderSize] ^= 1
+	if err := ioutil.WriteFile(fn, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c3 := &cache{epoch: epoch{number: 1}, test: true, dir: dir}
+	if err := c3.load(cacheSizeForTesting); err == nil {
+		t.Fatal("corrupted cache file is loaded")
+	}
+	c3.generate()
+	if c3.nodes[0] != c1.nodes[0] {
+		t.Fatal("regenerated cache differs")
+	}
+}
@@ -1,42 +1,27 @@
 package ethash
 
 import (
-	"hash"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/sudachen/playground/sha3"
 	"unsafe"
 )
 
-type h interface {
-	hash.Hash
-	Out() []byte
-}
-
+// keccakf hashes nodes by Keccak-f1600 permutation over node words,
+// input is absorbed into the state directly without byte buffers.
+// Lanes are little-endian, as nodes are, so it works on little-endian machines only.
 type keccakf struct {
-	h
-	b []byte
+	a [25]uint64
 }
 
-func copyNodeToB64(src *node, dst []byte) {
-	*(*node)(unsafe.Pointer(&dst[0])) = *src
-}
+const keccakPad = 0x01
+const keccakPadLast = 0x80 << 56
+const keccak512Rate = 72/8
+const keccak256Rate = 136/8
 
 type c32 [32]byte
 func copyNodeToB32(src *node, dst []byte) {
 	copy(dst,(*c32)(unsafe.Pointer(src))[:])
 }
 
-func copyB64ToNode(src []byte, dst *node) {
-	*dst = *(*node)(unsafe.Pointer(&src[0]))
-}
-
-type b40 [10]uint32
-func copyB40ToNode(src []byte, dst *node) {
-	s := (*b40)(unsafe.Pointer(&src[0]))
-	d := (*b40)(unsafe.Pointer(&dst.w[0]))
-	*d = *s
-}
-
 type b32 [8]uint32
 func copyB32ToNode(src []byte, dst *node) {
 	s := (*b32)(unsafe.Pointer(&src[0]))
@@ -44,26 +29,48 @@ func copyB32ToNode(src []byte, dst *node) {
 	*d = *s
 }
 
+func lanes(n *node) *nodeQ {
+	return (*nodeQ)(unsafe.Pointer(n))
+}
+
+// sum512 absorbs n lanes already placed into the state and squeezes 64 bytes
+func (h *keccakf) sum512(out *node, n int) {
+	h.a[n] = keccakPad
+	h.a[keccak512Rate-1] |= keccakPadLast
+	keccakF1600(&h.a)
+	copy(lanes(out)[:], h.a[:8])
+}
+
 func (h *keccakf) h64(out *node, in *node) {
-	h.Reset()
-	copyNodeToB64(in,h.b)
-	h.Write(h.b)
-	copyB64ToNode(h.Out(),out)
+	h.a = [25]uint64{}
+	copy(h.a[:8], lanes(in)[:])
+	h.sum512(out, 8)
 }
 
 func (h *keccakf) h32(out *node, in *common.Hash) {
-	h.Reset()
-	h.Write(in[:])
-	copyB64ToNode(h.Out(),out)
+	h.a = [25]uint64{}
+	copy(h.a[:4], (*[4]uint64)(unsafe.Pointer(&in[0]))[:])
+	h.sum512(out, 4)
 }
 
 func (h *keccakf) h40(out *node, in *node) {
-	h.Reset()
-	copyNodeToB64(in,h.b)
-	h.Write(h.b[0:40])
-	copyB64ToNode(h.Out(),out)
+	h.a = [25]uint64{}
+	copy(h.a[:5], lanes(in)[:5])
+	h.sum512(out, 5)
+}
+
+// h96 is keccak256 of the node followed by the hash
+func (h *keccakf) h96(in *node, in2 *common.Hash) (out common.Hash) {
+	h.a = [25]uint64{}
+	copy(h.a[:8], lanes(in)[:])
+	copy(h.a[8:12], (*[4]uint64)(unsafe.Pointer(&in2[0]))[:])
+	h.a[12] = keccakPad
+	h.a[keccak256Rate-1] |= keccakPadLast
+	keccakF1600(&h.a)
+	copy((*[4]uint64)(unsafe.Pointer(&out[0]))[:], h.a[:4])
+	return
 }
 
 func new512() *keccakf {
-	return &keccakf{h:sha3.NewKeccak512().(h),b:make([]byte,64)}
+	return &keccakf{}
 }
@@ -0,0 +1,9 @@
+// +build amd64
+
+package ethash
+
+// This function is implemented in keccakf1600_amd64.s.
+
+//go:noescape
+
+func keccakF1600(a *[25]uint64)
@@ -0,0 +1,7 @@
+// +build !amd64
+
+package ethash
+
+func keccakF1600(a *[25]uint64) {
+	keccakF1600Generic(a)
+}
@@ -0,0 +1,128 @@
+package ethash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+)
+
+func randomNode() (n node) {
+	for i := range n.w {
+		n.w[i] = rand.Uint32()
+	}
+	return
+}
+
+func nodeBytes(n *node) []byte {
+	b := make([]byte, NodeSize)
+	for i, w := range n.w {
+		binary.LittleEndian.PutUint32(b[i*4:], w)
+	}
+	return b
+}
+
+func keccak512(b []byte) []byte {
+	h := sha3.NewKeccak512()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func TestKeccakf(t *testing.T) {
+	k := new512()
+	for i := 0; i < 100; i++ {
+		in := randomNode()
+		b := nodeBytes(&in)
+		var out node
+
+		k.h64(&out, &in)
+		if !bytes.Equal(nodeBytes(&out), keccak512(b)) {
+			t.Fatal("h64 differs from keccak512")
+		}
+		k.h40(&out, &in)
+		if !bytes.Equal(nodeBytes(&out), keccak512(b[:40])) {
+			t.Fatal("h40 differs from keccak512")
+		}
+		hash := common.BytesToHash(b[:32])
+		k.h32(&out, &hash)
+		if !bytes.Equal(nodeBytes(&out), keccak512(b[:32])) {
+			t.Fatal("h32 differs from keccak512")
+		}
+
+		var mix common.Hash
+		rand.Read(mix[:])
+		h := sha3.NewKeccak256()
+		h.Write(b)
+		h.Write(mix[:])
+		if r := k.h96(&in, &mix); !bytes.Equal(r[:], h.Sum(nil)) {
+			t.Fatal("h96 differs from keccak256")
+		}
+	}
+}
+
+func TestKeccakF1600Generic(t *testing.T) {
+	var a, b [25]uint64
+	for i := range a {
+		a[i] = rand.Uint64()
+	}
+	b = a
+	keccakF1600(&a)
+	keccakF1600Generic(&b)
+	if a != b {
+		t.Fatal("keccakF1600 differs from generic implementation")
+	}
+}
+
+func BenchmarkH64(b *testing.B) {
+	k := new512()
+	n := randomNode()
+	for i := 0; i < b.N; i++ {
+		k.h64(&n, &n)
+	}
+}
+
+func BenchmarkH40(b *testing.B) {
+	k := new512()
+	n := randomNode()
+	for i := 0; i < b.N; i++ {
+		k.h40(&n, &n)
+	}
+}
+
+func BenchmarkH32(b *testing.B) {
+	k := new512()
+	var n node
+	hash := common.BytesToHash([]byte{1, 2, 3})
+	for i := 0; i < b.N; i++ {
+		k.h32(&n, &hash)
+	}
+}
+
+// BenchmarkKeccak512 is the hash.Hash path the node hashes replaced
+func BenchmarkKeccak512(b *testing.B) {
+	h := sha3.NewKeccak512()
+	buf := make([]byte, NodeSize)
+	out := make([]byte, 0, NodeSize)
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		h.Write(buf)
+		out = h.Sum(out[:0])
+	}
+}
+
+func BenchmarkKeccakF1600(b *testing.B) {
+	var a [25]uint64
+	for i := 0; i < b.N; i++ {
+		keccakF1600(&a)
+	}
+}
+
+func BenchmarkKeccakF1600Generic(b *testing.B) {
+	var a [25]uint64
+	for i := 0; i < b.N; i++ {
+		keccakF1600Generic(&a)
+	}
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/pow"
 	"github.com/ethereum/go-ethereum/common"
-	"fmt"
 )
 
 var maxUint256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
@@ -21,26 +20,79 @@ var maxUint256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 type Light struct {
 	test bool // If set, use a smaller cache size
 
-	mu     sync.Mutex        // Protects the per-epoch map of verification caches
-	caches map[uint64]*cache // Currently maintained verification caches
-	future *cache            // Pre-generated cache for the estimated future DAG
+	mu     sync.Mutex       // Protects the per-epoch map of verification caches
+	caches map[epoch]*cache // Currently maintained verification caches
+	future *cache           // Pre-generated cache for the estimated future DAG
 
-	NumCaches int // Maximum number of caches to keep before eviction (only init, don't modify)
+	NumCaches int    // Maximum number of caches to keep before eviction (only init, don't modify)
+	Dir       string // Directory of cache files, caches are generated on every start if empty
+
+	// Maximum count of epochs the verified block may be ahead of the known head,
+	// the head is genesis until it's set by SetHead (only init, don't modify)
+	MaxEpochsAhead uint64
+	head           uint64 // The highest known block number, protected by mu
+
+	// Block since which the epoch length is doubled, ECIP1099Block on
+	// Ethereum Classic, epoch length is not changed if zero (only init, don't modify)
+	ECIP1099Block uint64
+}
+
+func (l *Light) epochOf(blockNum uint64) epoch {
+	return epochOf(blockNum, l.ECIP1099Block)
+}
+
+const defaultMaxEpochsAhead = 2
+
+// SetHead sets the known chain head, blocks far ahead of it are rejected
+// without cache generation, Verify does not advance the head, so it's set
+// by the caller when the verified block is accepted by the chain
+func (l *Light) SetHead(blockNum uint64) {
+	l.mu.Lock()
+	if blockNum > l.head {
+		l.head = blockNum
+	}
+	l.mu.Unlock()
+}
+
+// tooFarAhead returns true if the epoch of the block exceeds the limit
+// or it's beyond maxEpoch
+func (l *Light) tooFarAhead(blockNum uint64) bool {
+	if !l.epochOf(blockNum).valid() {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	max := l.MaxEpochsAhead
+	if max == 0 {
+		max = defaultMaxEpochsAhead
+	}
+	e := l.epochOf(blockNum)
+	return e.first() > l.epochOf(l.head).first()+max*e.len()
+}
+
+// quickVerify checks the result computed from the claimed mix digest
+// against the target, it does not require the cache, so headers with
+// random mix digests are rejected before any cache work
+func quickVerify(hash common.Hash, nonce uint64, mixDigest common.Hash, target *big.Int) bool {
+	var s node
+	k := new512()
+	seedNode(hash, nonce, &s, k)
+	return resultHash(&s, mixDigest, k).Big().Cmp(target) <= 0
 }
 
 func (l *Light) getCache(blockNum uint64) *cache {
 	var c *cache
-	epoch := blockNum / epochLength
+	e := l.epochOf(blockNum)
 
 	// If we have a PoW for that epoch, use that
 	l.mu.Lock()
 	if l.caches == nil {
-		l.caches = make(map[uint64]*cache)
+		l.caches = make(map[epoch]*cache)
 	}
 	if l.NumCaches == 0 {
 		l.NumCaches = 3
 	}
-	c = l.caches[epoch]
+	c = l.caches[e]
 	if c == nil {
 		// No cached DAG, evict the oldest if the cache limit was reached
 		if len(l.caches) >= l.NumCaches {
@@ -50,23 +102,24 @@ func (l *Light) getCache(blockNum uint64) *cache {
 					evict = cache
 				}
 			}
-			glog.V(logger.Debug).Infof("Evicting DAG for epoch %d in favour of epoch %d", evict.epoch, epoch)
+			glog.V(logger.Debug).Infof("Evicting DAG for epoch %v in favour of epoch %v", evict.epoch, e)
 			delete(l.caches, evict.epoch)
 		}
 		// If we have the new DAG pre-generated, use that, otherwise create a new one
-		if l.future != nil && l.future.epoch == epoch {
-			glog.V(logger.Debug).Infof("Using pre-generated DAG for epoch %d", epoch)
+		if l.future != nil && l.future.epoch == e {
+			glog.V(logger.Debug).Infof("Using pre-generated DAG for epoch %v", e)
 			c, l.future = l.future, nil
 		} else {
-			glog.V(logger.Debug).Infof("No pre-generated DAG available, creating new for epoch %d", epoch)
-			c = &cache{epoch: epoch, test: l.test}
+			glog.V(logger.Debug).Infof("No pre-generated DAG available, creating new for epoch %v", e)
+			c = &cache{epoch: e, test: l.test, dir: l.Dir}
 		}
-		l.caches[epoch] = c
+		l.caches[e] = c
 
 		// If we just used up the future cache, or need a refresh, regenerate
-		if l.future == nil || l.future.epoch <= epoch {
-			glog.V(logger.Debug).Infof("Pre-generating DAG for epoch %d", epoch+1)
-			l.future = &cache{epoch: epoch + 1, test: l.test}
+		if l.future == nil || l.future.epoch.first() <= e.first() {
+			next := l.epochOf(e.first() + e.len())
+			glog.V(logger.Debug).Infof("Pre-generating DAG for epoch %v", next)
+			l.future = &cache{epoch: next, test: l.test, dir: l.Dir}
 			go l.future.generate()
 		}
 	}
@@ -80,13 +133,7 @@ func (l *Light) getCache(blockNum uint64) *cache {
 
 // Verify checks whether the block's nonce is valid.
 func (l *Light) Verify(block pow.Block) bool {
-	// TODO: do ethash_quick_verify before getCache in order
-	// to prevent DOS attacks.
 	blockNum := block.NumberU64()
-	if blockNum >= epochLength*2048 {
-		glog.V(logger.Debug).Infof("block number %d too high, limit is %d", epochLength*2048)
-		return false
-	}
 
 	difficulty := block.Difficulty()
 	/* Cannot happen if block header diff is validated prior to PoW, but can
@@ -99,8 +146,18 @@ func (l *Light) Verify(block pow.Block) bool {
 		return false
 	}
 
+	target := new(big.Int).Div(maxUint256, difficulty)
+	if !quickVerify(block.HashNoNonce(), block.Nonce(), block.MixDigest(), target) {
+		glog.V(logger.Debug).Infof("block %d failed quick verification", blockNum)
+		return false
+	}
+	if l.tooFarAhead(blockNum) {
+		glog.V(logger.Debug).Infof("block %d is too far ahead of the known head", blockNum)
+		return false
+	}
+
 	cache := l.getCache(blockNum)
-	dagSize := datasize(blockNum)
+	dagSize := datasize(cache.epoch.number)
 	if l.test {
 		dagSize = dagSizeForTesting
 	}
@@ -112,12 +169,10 @@ func (l *Light) Verify(block pow.Block) bool {
 
 	// avoid mixdigest malleability as it's not included in a block's "hashNononce"
 	if block.MixDigest() != mixDigest {
-		fmt.Println("block.MixDigest() != mixDigest", block.MixDigest(), mixDigest )
 		return false
 	}
 
 	// The actual check.
-	target := new(big.Int).Div(maxUint256, difficulty)
 	return result.Big().Cmp(target) <= 0
 }
 
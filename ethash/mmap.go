@@ -0,0 +1,20 @@
+// +build !windows
+
+package ethash
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(f *os.File, size int, writable bool) ([]byte, error) {
+	prot := syscall.PROT_READ
+	if writable {
+		prot |= syscall.PROT_WRITE
+	}
+	return syscall.Mmap(int(f.Fd()), 0, size, prot, syscall.MAP_SHARED)
+}
+
+func munmapFile(mem []byte) error {
+	return syscall.Munmap(mem)
+}
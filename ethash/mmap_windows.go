@@ -0,0 +1,17 @@
+package ethash
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoMmap = errors.New("memory mapped dataset is not supported on windows")
+
+// dataset is generated in memory on windows
+func mmapFile(f *os.File, size int, writable bool) ([]byte, error) {
+	return nil, errNoMmap
+}
+
+func munmapFile(mem []byte) error {
+	return errNoMmap
+}
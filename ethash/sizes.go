@@ -0,0 +1,35 @@
+package ethash
+
+import "math/big"
+
+const (
+	cacheBytesInit     uint64 = 1 << 24
+	cacheBytesGrowth   uint64 = 1 << 17
+	datasetBytesInit   uint64 = 1 << 30
+	datasetBytesGrowth uint64 = 1 << 23
+)
+
+// calcCacheSize calculates the cache size of the epoch, it's the largest
+// multiple of NodeSize below the linear growth with the prime count of nodes
+func calcCacheSize(epoch uint64) uint64 {
+	size := cacheBytesInit + cacheBytesGrowth*epoch - NodeSize
+	for !isPrime(size / NodeSize) {
+		size -= 2 * NodeSize
+	}
+	return size
+}
+
+// calcDatasetSize calculates the dataset size of the epoch, it's the largest
+// multiple of MixBytes below the linear growth with the prime count of mixes
+func calcDatasetSize(epoch uint64) uint64 {
+	size := datasetBytesInit + datasetBytesGrowth*epoch - MixBytes
+	for !isPrime(size / MixBytes) {
+		size -= 2 * MixBytes
+	}
+	return size
+}
+
+// isPrime is exact for 64-bit numbers, ProbablyPrime applies Baillie-PSW test
+func isPrime(n uint64) bool {
+	return new(big.Int).SetUint64(n).ProbablyPrime(0)
+}
@@ -0,0 +1,68 @@
+package ethash
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCacheSizes(t *testing.T) {
+	for epoch, size := range cacheSizes {
+		if calc := calcCacheSize(uint64(epoch)); calc != size {
+			t.Fatalf("cache size of epoch %d is %d, table has %d", epoch, calc, size)
+		}
+	}
+}
+
+func TestDatasetSizes(t *testing.T) {
+	for epoch, size := range dagSizes {
+		if calc := calcDatasetSize(uint64(epoch)); calc != size {
+			t.Fatalf("dataset size of epoch %d is %d, table has %d", epoch, calc, size)
+		}
+	}
+}
+
+func TestSizesBeyondTables(t *testing.T) {
+	last := uint64(len(cacheSizes) - 1)
+	for epoch := last + 1; epoch < last+64; epoch++ {
+		if c := cachesize(epoch); c <= cachesize(epoch-1) || !isPrime(c/NodeSize) {
+			t.Fatalf("wrong cache size %d of epoch %d", c, epoch)
+		}
+		if d := datasize(epoch); d <= datasize(epoch-1) || !isPrime(d/MixBytes) {
+			t.Fatalf("wrong dataset size %d of epoch %d", d, epoch)
+		}
+	}
+}
+
+func TestECIP1099Epochs(t *testing.T) {
+	before := epochOf(ECIP1099Block-1, ECIP1099Block)
+	if before != (epoch{389, epochLength}) {
+		t.Fatalf("wrong epoch %v before ECIP-1099", before)
+	}
+	after := epochOf(ECIP1099Block, ECIP1099Block)
+	if after != (epoch{195, epochLength * 2}) {
+		t.Fatalf("wrong epoch %v since ECIP-1099", after)
+	}
+	if epochOf(ECIP1099Block, 0) != (epoch{390, epochLength}) {
+		t.Fatal("epoch length is changed without ECIP-1099")
+	}
+
+	// the seed of the doubled epoch is the seed of its first block,
+	// sizes and files are different
+	if after.seed() != makeSeedHash(390) {
+		t.Error("wrong seed hash since ECIP-1099")
+	}
+	second := ECIP1099Block + epochLength
+	if sh, _ := GetSeedHash(second, ECIP1099Block); common.BytesToHash(sh) != makeSeedHash(390) {
+		t.Error("seed hash is changed in the second half of the doubled epoch")
+	}
+	if sh, _ := GetSeedHash(second, 0); common.BytesToHash(sh) != makeSeedHash(391) {
+		t.Error("seed hash is not changed without ECIP-1099")
+	}
+	if CacheSize(ECIP1099Block, ECIP1099Block) != cacheSizes[195] {
+		t.Error("wrong cache size since ECIP-1099")
+	}
+	if fileSuffix(after, false) == fileSuffix(epoch{number: 390}, false) {
+		t.Error("ECIP-1099 epoch has the same file name")
+	}
+}
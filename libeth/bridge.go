@@ -0,0 +1,134 @@
+package libeth
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// StateDBView presents go-ethereum StateDB as read only State,
+// storage values are read by key when VM asks them, StateDB can't enumerate
+// them because the storage trie keeps only hashes of keys
+type StateDBView struct {
+	DB vm.StateDB
+}
+
+func (v *StateDBView) Exists(a Address) bool     { return v.DB.Exist(a) }
+func (v *StateDBView) HasSuicide(a Address) bool { return v.DB.HasSuicided(a) }
+func (v *StateDBView) Origin() State             { return nil }
+func (v *StateDBView) GetNonce(a Address) uint64 { return v.DB.GetNonce(a) }
+func (v *StateDBView) GetCode(a Address) []byte  { return v.DB.GetCode(a) }
+func (v *StateDBView) GetCodeSize(a Address) int { return v.DB.GetCodeSize(a) }
+func (v *StateDBView) Immutable() State          { return v }
+func (v *StateDBView) Logs() []*Log              { return nil }
+
+func (v *StateDBView) GetBalance(a Address) *big.Int {
+	return new(big.Int).Set(v.DB.GetBalance(a))
+}
+
+// GetCodeHash returns zero hash for accounts without code as State does
+func (v *StateDBView) GetCodeHash(a Address) Hash {
+	h := v.DB.GetCodeHash(a)
+	if h == emptyCodeHash {
+		return Hash{}
+	}
+	return h
+}
+
+func (v *StateDBView) GetValue(a Address, k Hash) (Hash, bool) {
+	val := v.DB.GetState(a, k)
+	return val, val != Hash{}
+}
+
+// ProcessValues does nothing, values are read by GetValue
+func (v *StateDBView) ProcessValues(a Address, f func(Hash, Hash) error, changedOnly bool) error {
+	return nil
+}
+
+// Addresses returns nil, StateDB can't enumerate accounts
+func (v *StateDBView) Addresses(changedOnly bool) []Address {
+	return nil
+}
+
+// ApplyState writes changed accounts and logs of the VM resulting state into StateDB
+func ApplyState(st State, db vm.StateDB) {
+	for _, a := range st.Addresses(true) {
+		if st.HasSuicide(a) {
+			db.Suicide(a)
+			continue
+		}
+		if !db.Exist(a) {
+			db.CreateAccount(a)
+		}
+		// balance is changed by AddBalance, so the account is touched even if
+		// balance is the same, it's required to remove empty accounts
+		delta := new(big.Int).Sub(st.GetBalance(a), db.GetBalance(a))
+		if delta.Sign() >= 0 {
+			db.AddBalance(a, delta)
+		} else {
+			db.SubBalance(a, delta.Neg(delta))
+		}
+		db.SetNonce(a, st.GetNonce(a))
+		if h := st.GetCodeHash(a); h != (Hash{}) && h != db.GetCodeHash(a) {
+			db.SetCode(a, st.GetCode(a))
+		}
+		st.ProcessValues(a, func(k, v Hash) error {
+			db.SetState(a, k, v)
+			return nil
+		}, true)
+	}
+	for _, l := range st.Logs() {
+		db.AddLog(&types.Log{
+			Address: l.Address,
+			Topics:  l.Topics,
+			Data:    l.Data,
+		})
+	}
+}
+
+type bridge struct {
+	vm VM
+}
+
+// Bridge executes VM over go-ethereum StateDB, so any VM can replay real blocks
+func Bridge(vm VM) VM1 {
+	return &bridge{vm}
+}
+
+// NewVM1 returns constructor of VM1 bridged to VM
+func NewVM1(newVM func() VM) func() VM1 {
+	return func() VM1 { return Bridge(newVM()) }
+}
+
+func (b *bridge) Execute(msg core.Message, bi *BlockInfo, sdb vm.StateDB) (uint64, bool, error) {
+	tx := &Transaction{
+		Data:     msg.Data(),
+		GasLimit: new(big.Int).SetUint64(msg.Gas()),
+		GasPrice: msg.GasPrice(),
+		Value:    msg.Value(),
+		Nonce:    msg.Nonce(),
+		To:       msg.To(),
+		From:     msg.From(),
+	}
+
+	info := *bi
+	if info.BigGasLimit == nil {
+		info.BigGasLimit = new(big.Int).SetUint64(bi.GasLimit)
+	}
+	info.RuleSet = bi.ResolveRules()
+
+	_, usedGas, st, err := b.vm.Execute(tx, &info, &StateDBView{sdb})
+	failed := false
+	if _, ok := err.(*ExecutionFailed); ok {
+		failed = true
+	} else if err != nil {
+		return 0, false, err
+	}
+	ApplyState(st, sdb)
+	return usedGas.Uint64(), failed, nil
+}
@@ -0,0 +1,154 @@
+package libeth_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/sudachen/playground/libeth"
+	mstate "github.com/sudachen/playground/libeth/state"
+)
+
+var (
+	bridgeFrom     = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	bridgeContract = common.HexToAddress("0x2000000000000000000000000000000000000002")
+
+	// values with leading zeros and high bits are encoded by RLP
+	// in the storage trie with their length prefix
+	bridgeSlots = map[common.Hash]common.Hash{
+		common.HexToHash("0x00"): common.HexToHash("0x01"),
+		common.HexToHash("0x01"): common.HexToHash("0x00000000000000000000000000000000000000000000000000000000deadbeef"),
+		common.HexToHash("0x02"): common.HexToHash("0xff00000000000000000000000000000000000000000000000000000000000001"),
+		common.HexToHash("0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563"): common.HexToHash("0x80"),
+	}
+)
+
+// copyVM copies the value of slot From into slot To, and counts
+// the loaded slots into slot 0x10 after the account is touched
+type copyVM struct {
+	from, to common.Hash
+	fail     bool
+}
+
+func (vm *copyVM) Execute(tx *libeth.Transaction, bi *libeth.BlockInfo, st libeth.State) ([]byte, *big.Int, libeth.State, error) {
+	ms := mstate.NewMicroState(st)
+	ms.SetNonce(tx.From, ms.GetNonce(tx.From)+1)
+	ms.SetBalance(*tx.To, new(big.Int).Add(ms.GetBalance(*tx.To), tx.Value))
+	v, _ := ms.GetValue(*tx.To, vm.from)
+	ms.SetValue(*tx.To, vm.to, v)
+	n := int64(0)
+	for k := range bridgeSlots {
+		if _, ok := ms.GetValue(*tx.To, k); ok {
+			n++
+		}
+	}
+	ms.SetValue(*tx.To, common.HexToHash("0x10"), common.BigToHash(big.NewInt(n)))
+	var err error
+	if vm.fail {
+		err = &libeth.ExecutionFailed{Err: errors.New("out of gas")}
+	}
+	return nil, big.NewInt(21000), ms.Freeze(), err
+}
+
+func bridgeState(t *testing.T) *state.StateDB {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	sdb.SetBalance(bridgeFrom, big.NewInt(1e18))
+	sdb.SetCode(bridgeContract, []byte{0x00})
+	for k, v := range bridgeSlots {
+		sdb.SetState(bridgeContract, k, v)
+	}
+	root, err := sdb.CommitTo(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reopened state has no preimages of storage keys as the state of the real chain
+	if sdb, err = state.New(root, state.NewDatabase(db)); err != nil {
+		t.Fatal(err)
+	}
+	return sdb
+}
+
+func TestBridgeStorage(t *testing.T) {
+	for from := range bridgeSlots {
+		sdb := bridgeState(t)
+		to := common.HexToHash("0x20")
+		msg := types.NewMessage(bridgeFrom, &bridgeContract, 0, big.NewInt(5), 50000, big.NewInt(1), nil, false)
+		bi := &libeth.BlockInfo{Header: types.Header{Number: big.NewInt(1), GasLimit: 1000000}}
+
+		gas, failed, err := libeth.Bridge(&copyVM{from: from, to: to}).Execute(msg, bi, sdb)
+		if err != nil || failed || gas != 21000 {
+			t.Fatalf("wrong execution %v %v %v", gas, failed, err)
+		}
+		if v := sdb.GetState(bridgeContract, to); v != bridgeSlots[from] {
+			t.Errorf("slot %x: copied value %x, want %x", from, v, bridgeSlots[from])
+		}
+		if v := sdb.GetState(bridgeContract, common.HexToHash("0x10")); v != common.BigToHash(big.NewInt(int64(len(bridgeSlots)))) {
+			t.Errorf("slot %x: values are not loaded from StateDB after the account is touched: %x", from, v)
+		}
+		for k, v := range bridgeSlots {
+			if x := sdb.GetState(bridgeContract, k); x != v {
+				t.Errorf("slot %x: value %x is changed to %x", k, v, x)
+			}
+		}
+		if sdb.GetNonce(bridgeFrom) != 1 || sdb.GetBalance(bridgeContract).Int64() != 5 {
+			t.Errorf("wrong account state")
+		}
+	}
+}
+
+func TestBridgeRoot(t *testing.T) {
+	from, to := common.HexToHash("0x01"), common.HexToHash("0x02")
+
+	// the same changes applied by go-ethereum StateDB directly
+	expected := bridgeState(t)
+	expected.SetNonce(bridgeFrom, 1)
+	expected.AddBalance(bridgeContract, big.NewInt(5))
+	expected.SetState(bridgeContract, to, bridgeSlots[from])
+	expected.SetState(bridgeContract, common.HexToHash("0x10"), common.BigToHash(big.NewInt(int64(len(bridgeSlots)))))
+
+	sdb := bridgeState(t)
+	msg := types.NewMessage(bridgeFrom, &bridgeContract, 0, big.NewInt(5), 50000, big.NewInt(1), nil, false)
+	bi := &libeth.BlockInfo{Header: types.Header{Number: big.NewInt(1), GasLimit: 1000000}}
+	_, failed, err := libeth.Bridge(&copyVM{from: from, to: to, fail: true}).Execute(msg, bi, sdb)
+	if err != nil || !failed {
+		t.Fatalf("failure is not propagated %v %v", failed, err)
+	}
+	if root, want := sdb.IntermediateRoot(true), expected.IntermediateRoot(true); root != want {
+		t.Errorf("state root %x, want %x", root, want)
+	}
+}
+
+// the immutable copy made by VM keeps reading values not loaded yet
+// from StateDB, and neither the copy nor StateDB is changed by the origin
+func TestBridgeImmutable(t *testing.T) {
+	sdb := bridgeState(t)
+	to := common.HexToHash("0x20")
+	ms := mstate.NewMicroState(&libeth.StateDBView{DB: sdb})
+	ms.SetValue(bridgeContract, to, common.HexToHash("0x01"))
+	im := ms.Immutable()
+	ms.SetValue(bridgeContract, to, common.HexToHash("0x02"))
+	ms.SetBalance(bridgeContract, big.NewInt(5))
+
+	if v, ok := im.GetValue(bridgeContract, to); !ok || v != common.HexToHash("0x01") {
+		t.Errorf("value %x of the copy is changed", v)
+	}
+	if im.GetBalance(bridgeContract).Sign() != 0 {
+		t.Errorf("balance of the copy is changed")
+	}
+	for k, v := range bridgeSlots {
+		if x, ok := im.GetValue(bridgeContract, k); !ok || x != v {
+			t.Errorf("slot %x: value %x is not read from StateDB, want %x", k, x, v)
+		}
+	}
+	if v := sdb.GetState(bridgeContract, to); v != (common.Hash{}) || sdb.GetBalance(bridgeContract).Sign() != 0 {
+		t.Errorf("StateDB is changed")
+	}
+}
@@ -59,6 +59,7 @@ type stData struct {
 	change     Change
 	newborn    bool
 	hasSuicide bool
+	copied     bool // values which are not in the map are in the origin
 }
 
 type stAccount struct {
@@ -77,10 +78,8 @@ func (acc *stData) CopyFrom(origin libeth.State, address common.Address) {
 		acc.code = &stCode{make([]byte, len(originCode)), crypto.Keccak256Hash(originCode)}
 		copy(acc.code.code, originCode)
 	}
-	origin.ProcessValues(address, func(key, val common.Hash) error {
-		acc.values[key] = &stValue{false, val}
-		return nil
-	}, false)
+	// values are read from the origin when they are requested
+	acc.copied = true
 }
 
 type stLogs struct {
@@ -197,6 +196,9 @@ func (st *MicroState) GetValue(address common.Address, key common.Hash) (common.
 			if ok {
 				return val.value, true
 			}
+			if acc.data.copied && st.origin != nil {
+				return st.origin.GetValue(address, key)
+			}
 		}
 	} else if st.origin != nil {
 		return st.origin.GetValue(address, key)
@@ -214,6 +216,14 @@ func (st *MicroState) ProcessValues(address common.Address, f func(common.Hash,
 					}
 				}
 			}
+			if acc.data.copied && st.origin != nil && !changedOnly {
+				return st.origin.ProcessValues(address, func(key, val common.Hash) error {
+					if _, ok := acc.data.values[key]; ok {
+						return nil
+					}
+					return f(key, val)
+				}, false)
+			}
 		}
 	} else if st.origin != nil && !changedOnly {
 		return st.origin.ProcessValues(address, f, false)
@@ -267,7 +277,7 @@ func (st *MicroState) Immutable() libeth.State {
 		logs:     stLogs{copyLogs(st.logs.records), nil},
 		snapshot: 0,
 		mutable:  false,
-		origin:   nil}
+		origin:   st.origin}
 
 	for addr, dt := range st.state {
 		acc := &stAccount{&stData{}, nil}
@@ -0,0 +1,202 @@
+package libeth
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/sudachen/playground/crypto"
+)
+
+// Chain ids of Ethereum Classic mainnet and Morden testnet,
+// EIP-155 replay protection is enabled there by the Diehard fork
+var (
+	ClassicChainId = big.NewInt(61)
+	MordenChainId  = big.NewInt(62)
+)
+
+var (
+	ErrUnsigned       = errors.New("transaction is not signed")
+	ErrInvalidSig     = errors.New("invalid transaction v, r, s values")
+	ErrInvalidChainId = errors.New("invalid chain id for signer")
+)
+
+var big8 = big.NewInt(8)
+
+// txdata is the canonical RLP layout of the transaction
+type txdata struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	GasLimit *big.Int
+	To       *Address `rlp:"nil"`
+	Value    *big.Int
+	Data     []byte
+	V, R, S  *big.Int
+}
+
+func bigOrZero(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+func (tx *Transaction) data() *txdata {
+	return &txdata{
+		Nonce:    tx.Nonce,
+		GasPrice: bigOrZero(tx.GasPrice),
+		GasLimit: bigOrZero(tx.GasLimit),
+		To:       tx.To,
+		Value:    bigOrZero(tx.Value),
+		Data:     tx.Data,
+		V:        bigOrZero(tx.V),
+		R:        bigOrZero(tx.R),
+		S:        bigOrZero(tx.S),
+	}
+}
+
+// EncodeRLP implements rlp.Encoder, unsigned transaction has zero v, r, s
+func (tx *Transaction) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, tx.data())
+}
+
+// DecodeRLP implements rlp.Decoder, the sender is not recovered
+func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
+	var d txdata
+	if err := s.Decode(&d); err != nil {
+		return err
+	}
+	*tx = Transaction{
+		Data:     d.Data,
+		GasLimit: d.GasLimit,
+		GasPrice: d.GasPrice,
+		Value:    d.Value,
+		Nonce:    d.Nonce,
+		To:       d.To,
+		V:        d.V,
+		R:        d.R,
+		S:        d.S,
+	}
+	return nil
+}
+
+// DecodeTransaction decodes the raw signed transaction
+func DecodeTransaction(raw []byte) (*Transaction, error) {
+	tx := &Transaction{}
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func rlpHash(x interface{}) (h Hash) {
+	b, _ := rlp.EncodeToBytes(x)
+	return crypto.Keccak256Hash(b)
+}
+
+// Hash returns the hash of the signed transaction
+func (tx *Transaction) Hash() Hash {
+	return rlpHash(tx)
+}
+
+// SigHash returns the hash signed by the sender, it includes the chain id
+// if it's not nil, so the signature is not valid on other chains (EIP-155)
+func (tx *Transaction) SigHash(chainId *big.Int) Hash {
+	d := tx.data()
+	fields := []interface{}{d.Nonce, d.GasPrice, d.GasLimit, d.To, d.Value, d.Data}
+	if chainId != nil {
+		fields = append(fields, chainId, uint(0), uint(0))
+	}
+	return rlpHash(fields)
+}
+
+// Protected returns true if the transaction is signed with the chain id
+func (tx *Transaction) Protected() bool {
+	if tx.V == nil {
+		return false
+	}
+	if tx.V.BitLen() > 8 {
+		return true
+	}
+	v := tx.V.Uint64()
+	return v != 0 && v != 27 && v != 28
+}
+
+// ChainId returns the chain id of the protected transaction, it's nil otherwise
+func (tx *Transaction) ChainId() *big.Int {
+	if !tx.Protected() {
+		return nil
+	}
+	v := new(big.Int).Sub(tx.V, big.NewInt(35))
+	return v.Div(v, big.NewInt(2))
+}
+
+// SignerChainId returns the chain id of signatures valid in the block, it's nil
+// before Diehard, so only transactions without replay protection are accepted
+func (r *RuleSet) SignerChainId(number *big.Int) *big.Int {
+	if r.DiehardBlock == nil || number.Cmp(r.DiehardBlock) < 0 {
+		return nil
+	}
+	return r.ChainId
+}
+
+// Sign signs the transaction and sets its sender, chain id is nil
+// for Homestead signature without replay protection
+func (tx *Transaction) Sign(prv *ecdsa.PrivateKey, chainId *big.Int) error {
+	h := tx.SigHash(chainId)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return err
+	}
+	tx.R = new(big.Int).SetBytes(sig[:32])
+	tx.S = new(big.Int).SetBytes(sig[32:64])
+	tx.V = big.NewInt(int64(sig[64]) + 27)
+	if chainId != nil {
+		// v = chainId*2 + 35 + recovery id
+		tx.V.Add(tx.V, new(big.Int).Mul(chainId, big.NewInt(2)))
+		tx.V.Add(tx.V, big8)
+	}
+	tx.From = crypto.PubkeyToAddress(prv.PublicKey)
+	return nil
+}
+
+// Sender recovers the address of the signer and sets From. Protected
+// transaction must be signed for the chain id, not protected transactions
+// are accepted on any chain. Homestead rejects signatures with high s values.
+func (tx *Transaction) Sender(chainId *big.Int, homestead bool) (Address, error) {
+	if tx.V == nil || tx.V.Sign() == 0 {
+		return Address{}, ErrUnsigned
+	}
+	v := new(big.Int).Set(tx.V)
+	sigChainId := tx.ChainId()
+	if sigChainId != nil {
+		if chainId == nil || sigChainId.Cmp(chainId) != 0 {
+			return Address{}, ErrInvalidChainId
+		}
+		v.Sub(v, new(big.Int).Mul(sigChainId, big.NewInt(2)))
+		v.Sub(v, big8)
+	}
+	if v.BitLen() > 8 || tx.R == nil || tx.S == nil ||
+		!crypto.ValidateSignatureValues(byte(v.Uint64()), tx.R, tx.S, homestead) {
+		return Address{}, ErrInvalidSig
+	}
+
+	h := tx.SigHash(sigChainId)
+	sig := make([]byte, 65)
+	copy(sig[32-len(tx.R.Bytes()):32], tx.R.Bytes())
+	copy(sig[64-len(tx.S.Bytes()):64], tx.S.Bytes())
+	sig[64] = byte(v.Uint64() - 27)
+
+	pub, err := crypto.Ecrecover(h[:], sig)
+	if err != nil {
+		return Address{}, err
+	}
+	if len(pub) == 0 || pub[0] != 4 {
+		return Address{}, errors.New("invalid public key")
+	}
+	tx.From = common.BytesToAddress(crypto.Keccak256(pub[1:])[12:])
+	return tx.From, nil
+}
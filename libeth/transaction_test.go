@@ -0,0 +1,180 @@
+package libeth_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/sudachen/playground/crypto"
+	"github.com/sudachen/playground/libeth"
+)
+
+var txKey, _ = crypto.HexToECDSA("4646464646464646464646464646464646464646464646464646464646464646")
+
+func testTransactions() []*libeth.Transaction {
+	to := common.HexToAddress("0x3535353535353535353535353535353535353535")
+	return []*libeth.Transaction{
+		{Nonce: 9, GasPrice: big.NewInt(20e9), GasLimit: big.NewInt(21000), To: &to, Value: big.NewInt(1e18)},
+		{Nonce: 0, GasPrice: big.NewInt(1), GasLimit: big.NewInt(100000), Value: new(big.Int), Data: common.FromHex("0x6001600101600055")},
+		{Nonce: 1 << 20, GasPrice: new(big.Int), GasLimit: big.NewInt(53000), To: &to, Value: big.NewInt(1), Data: []byte{0}},
+	}
+}
+
+// gethTransaction is the same transaction made by go-ethereum
+func gethTransaction(tx *libeth.Transaction) *types.Transaction {
+	if tx.To == nil {
+		return types.NewContractCreation(tx.Nonce, tx.Value, tx.GasLimit.Uint64(), tx.GasPrice, tx.Data)
+	}
+	return types.NewTransaction(tx.Nonce, *tx.To, tx.Value, tx.GasLimit.Uint64(), tx.GasPrice, tx.Data)
+}
+
+func gethSigner(chainId *big.Int) types.Signer {
+	if chainId == nil {
+		return types.HomesteadSigner{}
+	}
+	return types.NewEIP155Signer(chainId)
+}
+
+// the example of EIP-155 signed for Ethereum mainnet
+func TestTransactionEIP155Example(t *testing.T) {
+	tx := testTransactions()[0]
+	if h := tx.SigHash(big.NewInt(1)); h != common.HexToHash("0xdaf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53") {
+		t.Errorf("wrong signing hash %x", h)
+	}
+	if err := tx.Sign(txKey, big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := common.FromHex("0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83")
+	if !bytes.Equal(raw, expected) {
+		t.Errorf("wrong signed transaction %x", raw)
+	}
+}
+
+func TestTransactionRLP(t *testing.T) {
+	for i, tx := range testTransactions() {
+		for _, chainId := range []*big.Int{nil, libeth.ClassicChainId, libeth.MordenChainId} {
+			if err := tx.Sign(txKey, chainId); err != nil {
+				t.Fatal(err)
+			}
+			gtx, err := types.SignTx(gethTransaction(tx), gethSigner(chainId), txKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, _ := rlp.EncodeToBytes(tx)
+			graw, _ := rlp.EncodeToBytes(gtx)
+			if !bytes.Equal(raw, graw) {
+				t.Fatalf("tx %d chain %v: encoded %x, go-ethereum %x", i, chainId, raw, graw)
+			}
+			if tx.Hash() != gtx.Hash() {
+				t.Errorf("tx %d chain %v: hash %x, go-ethereum %x", i, chainId, tx.Hash(), gtx.Hash())
+			}
+
+			dtx, err := libeth.DecodeTransaction(graw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if draw, _ := rlp.EncodeToBytes(dtx); !bytes.Equal(draw, graw) {
+				t.Errorf("tx %d chain %v: decoded transaction is encoded as %x", i, chainId, draw)
+			}
+			if (dtx.To == nil) != (tx.To == nil) || dtx.Nonce != tx.Nonce || !bytes.Equal(dtx.Data, tx.Data) {
+				t.Errorf("tx %d chain %v: wrong decoded transaction", i, chainId)
+			}
+			if c := dtx.ChainId(); (c == nil) != (chainId == nil) || c != nil && c.Cmp(chainId) != 0 {
+				t.Errorf("tx %d chain %v: wrong chain id %v", i, chainId, c)
+			}
+		}
+	}
+}
+
+func TestTransactionSender(t *testing.T) {
+	from := crypto.PubkeyToAddress(txKey.PublicKey)
+	for i, tx := range testTransactions() {
+		for _, chainId := range []*big.Int{nil, libeth.ClassicChainId, libeth.MordenChainId} {
+			gtx, err := types.SignTx(gethTransaction(tx), gethSigner(chainId), txKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			graw, _ := rlp.EncodeToBytes(gtx)
+			dtx, err := libeth.DecodeTransaction(graw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gfrom, err := types.Sender(gethSigner(chainId), gtx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sender, err := dtx.Sender(chainId, true)
+			if err != nil {
+				t.Fatalf("tx %d chain %v: %v", i, chainId, err)
+			}
+			if sender != gfrom || sender != from || dtx.From != from {
+				t.Errorf("tx %d chain %v: sender %x, go-ethereum %x", i, chainId, sender, gfrom)
+			}
+
+			// signatures of Ethereum Classic are not valid on Morden and vice versa
+			for _, other := range []*big.Int{libeth.ClassicChainId, libeth.MordenChainId} {
+				if chainId == nil || other.Cmp(chainId) == 0 {
+					continue
+				}
+				if _, err := dtx.Sender(other, true); err != libeth.ErrInvalidChainId {
+					t.Errorf("tx %d chain %v: signature is accepted on chain %v: %v", i, chainId, other, err)
+				}
+			}
+			// not protected transactions are valid on any chain
+			if chainId == nil {
+				if _, err := dtx.Sender(libeth.ClassicChainId, true); err != nil {
+					t.Errorf("tx %d: not protected transaction is rejected: %v", i, err)
+				}
+			} else if _, err := dtx.Sender(nil, true); err != libeth.ErrInvalidChainId {
+				t.Errorf("tx %d chain %v: protected transaction is accepted before EIP-155: %v", i, chainId, err)
+			}
+		}
+	}
+}
+
+func TestTransactionSenderErrors(t *testing.T) {
+	tx := testTransactions()[0]
+	if _, err := tx.Sender(nil, true); err != libeth.ErrUnsigned {
+		t.Errorf("unsigned transaction: %v", err)
+	}
+	if err := tx.Sign(txKey, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// high s value is valid before Homestead only
+	high := *tx
+	high.S = new(big.Int).Sub(crypto.S256().Params().N, tx.S)
+	high.V = big.NewInt(55 - tx.V.Int64())
+	if _, err := high.Sender(nil, true); err != libeth.ErrInvalidSig {
+		t.Errorf("high s value is accepted since Homestead: %v", err)
+	}
+	if from, err := high.Sender(nil, false); err != nil || from != crypto.PubkeyToAddress(txKey.PublicKey) {
+		t.Errorf("high s value before Homestead: %x %v", from, err)
+	}
+
+	if err := tx.Sign(txKey, libeth.ClassicChainId); err != nil {
+		t.Fatal(err)
+	}
+	bad := *tx
+	bad.R = new(big.Int)
+	if _, err := bad.Sender(libeth.ClassicChainId, true); err != libeth.ErrInvalidSig {
+		t.Errorf("zero r value: %v", err)
+	}
+}
+
+func TestRuleSetSignerChainId(t *testing.T) {
+	rules := (&libeth.BlockInfo{}).ResolveRules()
+	if c := rules.SignerChainId(big.NewInt(2999999)); c != nil {
+		t.Errorf("chain id %v before Diehard", c)
+	}
+	if c := rules.SignerChainId(big.NewInt(3000000)); c == nil || c.Cmp(libeth.ClassicChainId) != 0 {
+		t.Errorf("chain id %v since Diehard", c)
+	}
+}
@@ -17,6 +17,7 @@ type Transaction struct {
 	Nonce    uint64
 	To       *Address
 	From     Address
+	V, R, S  *big.Int // signature, nil if the transaction is not signed
 }
 
 type Message struct {
@@ -29,6 +30,7 @@ type RuleSet struct {
 	HomesteadGasRepriceBlock *big.Int
 	DiehardBlock             *big.Int
 	ExplosionBlock           *big.Int
+	ChainId                  *big.Int // EIP-155 chain id of signatures since Diehard
 }
 
 type BlockInfo struct {
@@ -39,24 +41,35 @@ type BlockInfo struct {
 	Config       *params.ChainConfig
 }
 
+// ResolveRules returns the rule set of the block, it's mapped from the chain
+// config if the rule set is not specified, mainnet schedule is used without both
 func (bi *BlockInfo) ResolveRules() *RuleSet {
 	if bi.RuleSet != nil {
 		return bi.RuleSet
 	}
-	hb := big.NewInt(1150000)
 	if bi.Config != nil {
-		hb = bi.Config.HomesteadBlock
-	}
-	dao := big.NewInt(1920000)
-	if bi.Config != nil {
-		dao = bi.Config.DAOForkBlock
+		return ConfigRules(bi.Config)
 	}
 	return &RuleSet{
-		HomesteadBlock:           hb,
-		DAOForkBlock:             dao,
+		HomesteadBlock:           big.NewInt(1150000),
+		DAOForkBlock:             big.NewInt(1920000),
 		HomesteadGasRepriceBlock: big.NewInt(2500000),
 		DiehardBlock:             big.NewInt(3000000),
 		ExplosionBlock:           big.NewInt(5000000),
+		ChainId:                  ClassicChainId,
+	}
+}
+
+// ConfigRules maps fork schedule of go-ethereum config to the classic VMs rules,
+// gas reprice is EIP-150 and Diehard is EIP-155 with EIP-160,
+// the config does not have difficulty bomb delay, so ExplosionBlock is nil
+func ConfigRules(cfg *params.ChainConfig) *RuleSet {
+	return &RuleSet{
+		HomesteadBlock:           cfg.HomesteadBlock,
+		DAOForkBlock:             cfg.DAOForkBlock,
+		HomesteadGasRepriceBlock: cfg.EIP150Block,
+		DiehardBlock:             cfg.EIP155Block,
+		ChainId:                  cfg.ChainId,
 	}
 }
 
@@ -68,6 +81,31 @@ type VM interface {
 		executionError error)
 }
 
+// ExecutionFailed is returned by VM when the transaction is valid, but its
+// execution is failed like out of gas, the gas is charged and the resulting
+// state is valid, other errors mean the transaction can't be applied
+type ExecutionFailed struct {
+	Err error
+}
+
+func (e *ExecutionFailed) Error() string {
+	return "execution failed: " + e.Err.Error()
+}
+
+// Execution is the transaction prepared for execution by PreparedVM
+type Execution interface {
+	Run() (out []byte, usedGas *big.Int, executionError error)
+	// Finish releases VM and returns resulting state
+	Finish() (resultState State)
+}
+
+// PreparedVM splits Execute into VM construction, execution and resulting
+// state freezing, so they can be measured separately
+type PreparedVM interface {
+	VM
+	Prepare(*Transaction, *BlockInfo, State) Execution
+}
+
 type StateDB interface {
 	vm.StateDB
 	IntermediateRoot(deleteEmptyObjects bool) common.Hash
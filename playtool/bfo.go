@@ -11,17 +11,22 @@ import (
 const DefaultRepeat = 29
 
 type Bfo struct {
-	Proc    func(int,map[string]interface{},string,*libeth.RuleSet,libeth.VM,*benchmark.T)error
+	Proc    func(*Bfo,*StateTest,string,*libeth.RuleSet,libeth.VM,*benchmark.T)error
 	NewVM   func() libeth.VM
 	RootDir string
 	Repeat  int
+	Phases  *Phases // if not nil, collects phases of every test
+	Samples *Samples // if not nil, collects time of every Repeat
+	Profile *Profile // if not nil, captures profiles of every test or group
 }
 
 func (bfo *Bfo) RunAll(tests []*Nfo, t *benchmark.T) {
 	for _, x := range tests {
 		if !x.Pass {
 			t.Run(x.Name, func(t0 *benchmark.T)error {
-				return x.RunAllBenchmarks(bfo,t0)
+				return bfo.Profile.capture(true, x.Name, func() error {
+					return x.RunAllBenchmarks(bfo,t0)
+				})
 			})
 		}
 	}
@@ -31,11 +36,13 @@ func (bfo *Bfo) RunOne(tests []*Nfo, name string, t *benchmark.T) {
 	p := strings.Split(name,"/")
 	t.Run(p[0], func(t0 *benchmark.T)error {
 		nfo := FindTest(tests, p[0])
-		if len(p) > 1 && p[1] != "*" {
-			return nfo.RunOneBenchmark(bfo, p[1], t0)
-		} else {
-			return nfo.RunAllBenchmarks(bfo, t0)
-		}
+		return bfo.Profile.capture(true, p[0], func() error {
+			if len(p) > 1 && p[1] != "*" {
+				return nfo.RunOneBenchmark(bfo, p[1], t0)
+			} else {
+				return nfo.RunAllBenchmarks(bfo, t0)
+			}
+		})
 
 	})
 }
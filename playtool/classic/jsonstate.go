@@ -1,14 +1,12 @@
 package classic
 
 import (
-	"errors"
 	"fmt"
 	"math/big"
-	"strconv"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/sudachen/playground/libeth"
 	"github.com/sudachen/playground/libeth/state"
+	"github.com/sudachen/playground/playtool"
 )
 
 /*
@@ -63,305 +61,100 @@ import (
    }
 */
 
-func strToBigInt(m map[string]interface{}, index string) (*big.Int, error) {
-	if v, ok := m[index]; !ok {
-		return nil, fmt.Errorf("there is no %s field", index)
-	} else {
-		if s, ok := v.(string); ok {
-			if ret, ok := new(big.Int).SetString(s, 0); ok {
-				return ret, nil
-			}
+func GetTransactionLogs(test *playtool.StateTest) []*libeth.Log {
+	ret := make([]*libeth.Log, 0, len(test.Logs))
+	for _, log := range test.Logs {
+		topics := make([]libeth.Hash, len(log.Topics))
+		for i, t := range log.Topics {
+			topics[i] = libeth.Hash(t)
 		}
-		return nil, fmt.Errorf("field %s has malformed value %s", index, v)
+		ret = append(ret, &libeth.Log{
+			Address: libeth.Address(log.Address),
+			Topics:  topics,
+			Data:    log.Data})
 	}
+	return ret
 }
 
-func strToUint64(m map[string]interface{}, index string) (uint64, error) {
-	if v, ok := m[index]; !ok {
-		return 0, fmt.Errorf("there is no %s field", index)
-	} else {
-		if s, ok := v.(string); ok {
-			if ret, err := strconv.ParseUint(s, 0, 64); err == nil {
-				return ret, nil
-			}
-		}
-		return 0, fmt.Errorf("field %s has malformed value %s", index, v)
-	}
-}
-
-func strToAddress(m map[string]interface{}, index string) (common.Address, error) {
-	if v, ok := m[index]; !ok {
-		return common.Address{}, fmt.Errorf("there is no %s field", index)
-	} else {
-		if s, ok := v.(string); ok {
-			return common.HexToAddress(s), nil
-		}
-		return common.Address{}, fmt.Errorf("field %s has malformed value %s", index, v)
-	}
-}
-
-func strToHash(m map[string]interface{}, index string) (common.Hash, error) {
-	if v, ok := m[index]; !ok {
-		return common.Hash{}, fmt.Errorf("there is no %s field", index)
-	} else {
-		if s, ok := v.(string); ok {
-			return common.HexToHash(s), nil
-		}
-		return common.Hash{}, fmt.Errorf("field %s has malformed value %s", index, v)
-	}
-}
-
-func strToAddressOpt(m map[string]interface{}, index string) (*common.Address, error) {
-	if v, ok := m[index]; !ok {
-		return nil, nil
-	} else {
-		if s, ok := v.(string); ok {
-			if len(s) >= 20*2 {
-				addr := common.HexToAddress(s)
-				return &addr, nil
-			}
-			return nil, nil
-		}
-		return nil, fmt.Errorf("field %s has malformed value %s", index, v)
-	}
-}
-
-func strToBytes(m map[string]interface{}, index string) ([]byte, error) {
-	if v, ok := m[index]; !ok {
-		return nil, fmt.Errorf("there is no %s field", index)
-	} else {
-		if s, ok := v.(string); ok {
-			return common.FromHex(s), nil
-		}
-		return nil, fmt.Errorf("field %s has malformed value %s", index, v)
-	}
-}
-
-func strToBytesOpt(m map[string]interface{}, index string) ([]byte, error) {
-	if v, ok := m[index]; !ok {
-		return make([]byte, 0), nil
-	} else {
-		if s, ok := v.(string); ok {
-			return common.FromHex(s), nil
-		}
-		return nil, fmt.Errorf("field %s has malformed value %s", index, v)
-	}
-}
-
-func setStorage(m map[string]interface{}, address common.Address, st libeth.MutableState) error {
-	if v, ok := m["storage"]; !ok {
-		return errors.New("there is no storage field")
-	} else {
-		if values, ok := v.(map[string]interface{}); ok {
-			for key, val := range values {
-				if s, success := val.(string); success {
-					st.SetValue(address, common.HexToHash(key), common.HexToHash(s))
-				} else {
-					return fmt.Errorf("storage key %s has malformed value", key)
-				}
-			}
-			return nil
-		}
-		return errors.New("field storage has malformed value")
-	}
-}
-
-func getString(m map[string]interface{}, index string) (string, error) {
-	if v, ok := m[index]; !ok {
-		return "", fmt.Errorf("there is no %s field", index)
-	} else {
-		if s, ok := v.(string); ok {
-			return s, nil
-		} else {
-			return "", fmt.Errorf("field %s has malformed value", index)
-		}
-	}
-}
-
-func getHashes(m map[string]interface{}, index string) ([]common.Hash, error) {
-	return nil, nil
-}
-
-func GetTransactionLogs(m map[string]interface{}) ([]*libeth.Log, error) {
-	ret := make([]*libeth.Log, 0, 3)
-	if v, ok := m["logs"]; ok {
-		if values, ok := v.([]interface{}); !ok {
-			return nil, errors.New("field storage has malformed value")
-		} else {
-			for _, v := range values {
-				if log, ok := v.(map[string]interface{}); ok {
-					var address string
-					var topics []common.Hash
-					var err error
-					if address, err = getString(log, "address"); err != nil {
-						return nil, fmt.Errorf("bad log record: %s", err.Error())
-					}
-					if topics, err = getHashes(log, "topics"); err != nil {
-						return nil, fmt.Errorf("bad log record: %s", err.Error())
-					}
-					if data, err := getString(log, "data"); err != nil {
-						return nil, fmt.Errorf("bad log record: %s", err.Error())
-					} else {
-						ret = append(ret, &libeth.Log{
-							Address: common.HexToAddress(address),
-							Topics:  topics,
-							Data:    common.FromHex(data)})
-					}
-				}
-			}
+func FillStateFrom(accounts playtool.Accounts, st libeth.MutableState) error {
+	for address, acc := range accounts {
+		st.SetBalance(address, acc.Balance.Big())
+		st.SetNonce(address, uint64(acc.Nonce))
+		if err := st.SetCode(address, acc.Code); err != nil {
+			return fmt.Errorf("%s : %s", address.Hex(), err.Error())
 		}
-	}
-	return ret, nil
-}
-
-func FillStateFrom(accounts map[string]interface{}, st libeth.MutableState) error {
-	for a, acc := range accounts {
-		if m, ok := acc.(map[string]interface{}); !ok {
-			return fmt.Errorf("malformed accouns set")
-		} else {
-			address := common.HexToAddress(a)
-
-			if balance, err := strToBigInt(m, "balance"); err != nil {
-				return fmt.Errorf("%s : %s", a, err.Error())
-			} else {
-				st.SetBalance(address, balance)
-			}
-
-			if nonce, err := strToUint64(m, "nonce"); err != nil {
-				return fmt.Errorf("%s : %s", a, err.Error())
-			} else {
-				st.SetNonce(address, nonce)
-			}
-
-			if code, err := strToBytesOpt(m, "code"); err != nil {
-				return fmt.Errorf("%s : %s", a, err.Error())
-			} else {
-				st.SetCode(address, code)
-			}
-
-			if err := setStorage(m, address, st); err != nil {
-				return fmt.Errorf("%s : %s", a, err.Error())
-			}
+		for key, val := range acc.Storage {
+			st.SetValue(address, key, val)
 		}
 	}
 	return nil
 }
 
-func GetPossibleForks(test map[string]interface{}) []string {
-	var ret []string
-	if m, ok := test["post"]; ok {
-		if v, ok := m.(map[string]interface{}); ok {
-			for s := range v {
-				ret = append(ret, s)
-			}
-		}
-	}
-	return ret
-}
-
-func NewPreState(test map[string]interface{}) (libeth.State, error) {
+func NewPreState(test *playtool.StateTest) (libeth.State, error) {
 	pre := state.NewMicroState(nil)
-	var err error
-
-	if m, ok := test["pre"]; ok {
-		if v, ok := m.(map[string]interface{}); ok {
-			if err = FillStateFrom(v, pre); err != nil {
-				return nil, err
-			}
-		}
+	if err := FillStateFrom(test.Pre, pre); err != nil {
+		return nil, err
 	}
-
 	return pre.Freeze(), nil
 }
 
-func NewClassicPostState(test map[string]interface{}) (libeth.State, error) {
+func NewClassicPostState(test *playtool.StateTest) (libeth.State, error) {
 	post := state.NewMicroState(nil)
-	var err error
-
-	if m, ok := test["post"]; ok {
-		if v, ok := m.(map[string]interface{}); ok {
-			if err = FillStateFrom(v, post); err != nil {
-				return nil, err
-			}
-		}
+	if err := FillStateFrom(test.Post, post); err != nil {
+		return nil, err
 	}
-
 	return post.Freeze(), nil
 }
 
-func GetTransaction(test map[string]interface{}) (*libeth.Transaction, error) {
-	var err error
-
-	if m, ok := test["transaction"]; ok {
-		if v, ok := m.(map[string]interface{}); ok {
-			tx := &libeth.Transaction{}
-			if tx.Data, err = strToBytes(v, "data"); err != nil {
-				return nil, err
-			}
-			if tx.GasLimit, err = strToBigInt(v, "gasLimit"); err != nil {
-				return nil, err
-			}
-			if tx.GasPrice, err = strToBigInt(v, "gasPrice"); err != nil {
-				return nil, err
-			}
-			if tx.Value, err = strToBigInt(v, "value"); err != nil {
-				return nil, err
-			}
-			if tx.Nonce, err = strToUint64(v, "nonce"); err != nil {
-				return nil, err
-			}
-			if tx.To, err = strToAddressOpt(v, "to"); err != nil {
-				return nil, err
-			}
-			return tx, nil
-		}
+func GetTransaction(test *playtool.StateTest) *libeth.Transaction {
+	v := &test.Transaction
+	return &libeth.Transaction{
+		Data:     v.Data,
+		GasLimit: v.GasLimit.Big(),
+		GasPrice: v.GasPrice.Big(),
+		Value:    v.Value.Big(),
+		Nonce:    uint64(v.Nonce),
+		To:       v.To.Address,
 	}
+}
 
-	return nil, errors.New("transaction does not exist in test definition")
+func GetSecretKey(test *playtool.StateTest) []byte {
+	return test.Transaction.SecretKey
 }
 
-func GetSecretKey(test map[string]interface{}) ([]byte, error) {
-	var err error
-	var secretKey []byte
-	if m, ok := test["transaction"]; ok {
-		if v, ok := m.(map[string]interface{}); ok {
-			if secretKey, err = strToBytes(v, "secretKey"); err != nil {
-				return nil, err
-			}
-			return secretKey, nil
-		}
+// CheckSignedTransaction validates the signed raw transaction of the test if it's present,
+// it must have fields of the test transaction and must be signed by its secret key for the chain id
+func CheckSignedTransaction(test *playtool.StateTest, tx *libeth.Transaction, chainId *big.Int, homestead bool) error {
+	if len(test.Transaction.Raw) == 0 {
+		return nil
+	}
+	signed, err := libeth.DecodeTransaction(test.Transaction.Raw)
+	if err != nil {
+		return fmt.Errorf("malformed signed transaction: %v", err)
+	}
+	from, err := signed.Sender(chainId, homestead)
+	if err != nil {
+		return fmt.Errorf("invalid signed transaction: %v", err)
+	}
+	if from != tx.From {
+		return fmt.Errorf("signed transaction sender %x does not match secret key of %x", from, tx.From)
 	}
-	return nil, errors.New("transaction secretKey does not exist in test definition")
+	if signed.SigHash(nil) != tx.SigHash(nil) {
+		return fmt.Errorf("signed transaction does not match the test transaction")
+	}
+	return nil
 }
 
-func GetTransactionOut(test map[string]interface{}) ([]byte, error) {
-	return strToBytes(test, "out")
+func GetTransactionOut(test *playtool.StateTest) []byte {
+	return test.Out
 }
 
-func FillBlockInfo(test map[string]interface{}, blockInfo *libeth.BlockInfo) error {
-	var err error
-	if m, ok := test["env"]; ok {
-		if env, ok := m.(map[string]interface{}); ok {
-			if blockInfo.Coinbase, err = strToAddress(env, "currentCoinbase"); err != nil {
-				return err
-			}
-			if blockInfo.Difficulty, err = strToBigInt(env, "currentGasLimit"); err != nil {
-				return err
-			}
-			if blockInfo.GasLimit, err = strToBigInt(env, "currentGasLimit"); err != nil {
-				return err
-			}
-			if blockInfo.Number, err = strToBigInt(env, "currentNumber"); err != nil {
-				return err
-			}
-			if blockInfo.Time, err = strToBigInt(env, "currentTimestamp"); err != nil {
-				return err
-			}
-			if blockInfo.ParentHash, err = strToHash(env, "previousHash"); err != nil {
-				return err
-			}
-			return nil
-		}
-	}
-	return errors.New("env does not exist in test definition or malformed")
+func FillBlockInfo(test *playtool.StateTest, blockInfo *libeth.BlockInfo) {
+	env := &test.Env
+	blockInfo.Coinbase = libeth.Address(env.Coinbase)
+	blockInfo.Difficulty = env.Difficulty.Big()
+	blockInfo.GasLimit = env.GasLimit.Big()
+	blockInfo.Number = env.Number.Big()
+	blockInfo.Time = env.Timestamp.Big()
+	blockInfo.ParentHash = libeth.Hash(env.PreviousHash)
 }
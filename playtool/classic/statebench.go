@@ -2,19 +2,16 @@ package classic
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/sudachen/benchmark"
 	"github.com/sudachen/playground/crypto"
 	"github.com/sudachen/playground/libeth"
+	"github.com/sudachen/playground/playtool"
 	"github.com/ethereum/go-ethereum/common"
 )
 
-func StateBench(repeat int, test map[string]interface{}, name string, rules *libeth.RuleSet, evm libeth.VM, t *benchmark.T) error {
-	var pre libeth.State
-	var tx *libeth.Transaction
-	var secretKey []byte
-	var err error
-
+func StateBench(bfo *playtool.Bfo, test *playtool.StateTest, name string, rules *libeth.RuleSet, evm libeth.VM, t *benchmark.T) error {
 	blockInfo := &libeth.BlockInfo{
 		Blockhash: func(n *big.Int) common.Hash {
 			return common.BytesToHash(crypto.Keccak256([]byte(n.String())))
@@ -22,23 +19,58 @@ func StateBench(repeat int, test map[string]interface{}, name string, rules *lib
 		RuleSet: rules,
 	}
 
-	if pre, err = NewPreState(test); err == nil {
-		if tx, err = GetTransaction(test); err == nil {
-			if secretKey, err = GetSecretKey(test); err == nil {
-				if err = FillBlockInfo(test, blockInfo); err == nil {
-					tx.From = crypto.PubkeyToAddress(crypto.ToECDSA(secretKey).PublicKey)
-				}
-			}
-		}
-	}
+	tx := GetTransaction(test)
+	tx.From = crypto.PubkeyToAddress(crypto.ToECDSA(GetSecretKey(test)).PublicKey)
+	FillBlockInfo(test, blockInfo)
+
+	phases := bfo.Phases.Test(name)
 
+	var pre libeth.State
+	var err error
+	phases.Measure(playtool.PreState, func() {
+		pre, err = NewPreState(test)
+	})
 	if err != nil {
 		return err
 	}
 
-	for i := 0; i <= repeat; i++ {
+	pvm, prepared := evm.(libeth.PreparedVM)
+
+	run := func(measure func(playtool.Phase, func())) {
+		if prepared {
+			var x libeth.Execution
+			measure(playtool.Construct, func() {
+				x = pvm.Prepare(tx, blockInfo, pre)
+			})
+			measure(playtool.Execute, func() {
+				x.Run()
+			})
+			measure(playtool.Freeze, func() {
+				x.Finish()
+			})
+		} else {
+			measure(playtool.Execute, func() {
+				evm.Execute(tx, blockInfo, pre)
+			})
+		}
+	}
+
+	if phases.CountAllocs() {
+		// allocations are counted before the timed runs,
+		// ReadMemStats stops the world
+		run(phases.MeasureAllocs)
+	}
+
+	for i := 0; i <= bfo.Repeat; i++ {
 		t.Start()
-		evm.Execute(tx, blockInfo, pre)
+		var started time.Time
+		if bfo.Samples != nil {
+			started = time.Now()
+		}
+		run(phases.MeasureTime)
+		if bfo.Samples != nil {
+			bfo.Samples.Add(name, time.Since(started))
+		}
 	}
 
 	return nil
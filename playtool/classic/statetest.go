@@ -13,14 +13,12 @@ import (
 	"github.com/sudachen/playground/crypto"
 	"github.com/sudachen/playground/libeth"
 	"github.com/sudachen/playground/libeth/state"
+	"github.com/sudachen/playground/playtool"
 )
 
-func StateTest(test map[string]interface{}, name string, rules *libeth.RuleSet, evm libeth.VM, t *testing.T) error {
+func StateTest(test *playtool.StateTest, name string, rules *libeth.RuleSet, evm libeth.VM, t *testing.T) error {
 	var pre libeth.State
 	var post libeth.State
-	var tx *libeth.Transaction
-	var secretKey []byte
-	var expectedOut []byte
 	var err error
 
 	if pre, err = NewPreState(test); err != nil {
@@ -29,17 +27,10 @@ func StateTest(test map[string]interface{}, name string, rules *libeth.RuleSet,
 	if post, err = NewClassicPostState(test); err != nil {
 		return err
 	}
-	if tx, err = GetTransaction(test); err != nil {
-		return err
-	}
-	if expectedOut, err = GetTransactionOut(test); err != nil {
-		return err
-	}
-	if secretKey, err = GetSecretKey(test); err != nil {
-		return err
-	}
 
-	tx.From = crypto.PubkeyToAddress(crypto.ToECDSA(secretKey).PublicKey)
+	tx := GetTransaction(test)
+	expectedOut := GetTransactionOut(test)
+	tx.From = crypto.PubkeyToAddress(crypto.ToECDSA(GetSecretKey(test)).PublicKey)
 
 	blockInfo := &libeth.BlockInfo{
 		Blockhash: func(n *big.Int) common.Hash {
@@ -47,8 +38,11 @@ func StateTest(test map[string]interface{}, name string, rules *libeth.RuleSet,
 		},
 		RuleSet: rules,
 	}
+	FillBlockInfo(test, blockInfo)
 
-	if err = FillBlockInfo(test, blockInfo); err != nil {
+	rs := blockInfo.ResolveRules()
+	homestead := rs.HomesteadBlock != nil && blockInfo.Number.Cmp(rs.HomesteadBlock) >= 0
+	if err := CheckSignedTransaction(test, tx, rs.SignerChainId(blockInfo.Number), homestead); err != nil {
 		return err
 	}
 
@@ -263,4 +257,17 @@ var StateTests = []*Nfo{
 		Skip:   []string{},
 		SkipTo: libeth.NulStr,
 	},
+	&Nfo{
+		Pass:   false,
+		Name:   "SignedTransaction",
+		File:   "stSignedTransactionTest.json",
+		Skip:   []string{},
+		SkipTo: libeth.NulStr,
+		Rules: &libeth.RuleSet{
+			HomesteadBlock:           big.NewInt(0),
+			HomesteadGasRepriceBlock: big.NewInt(0),
+			DiehardBlock:             big.NewInt(0),
+			ChainId:                  libeth.ClassicChainId,
+		},
+	},
 }
@@ -0,0 +1,128 @@
+package compare
+
+import (
+	"math"
+	"sort"
+)
+
+type Options struct {
+	Threshold float64 // relative slowdown to be reported as regression, 0.05 is 5%
+	Alpha     float64 // significance level, regression is reported only if p-value is less
+}
+
+var DefaultOptions = Options{
+	Threshold: 0.05,
+	Alpha:     0.05,
+}
+
+type Cell struct {
+	Mean       float64
+	Speedup    float64 // base time / target time, more than 1 means target is faster
+	PValue     float64 // NaN if there is not enough samples
+	Regression bool
+	Unsure     bool // slower than threshold, but there is not enough samples to tell
+}
+
+func (c *Cell) Significant(alpha float64) bool {
+	return !math.IsNaN(c.PValue) && c.PValue < alpha
+}
+
+type Row struct {
+	Name  string
+	Base  float64
+	Cells []*Cell // one per target, nil if target does not have test or it is failed
+}
+
+type Comparison struct {
+	Options
+	Base    *Run
+	Targets []*Run
+	Rows    []*Row
+	GeoMean []float64 // geometric mean of speedups per target
+	Matched []int     // count of matched tests per target
+}
+
+// Compare matches tests by name and compares every target run with the base run
+func Compare(opt Options, base *Run, targets ...*Run) *Comparison {
+	cmp := &Comparison{
+		Options: opt,
+		Base:    base,
+		Targets: targets,
+		GeoMean: make([]float64, len(targets)),
+		Matched: make([]int, len(targets)),
+	}
+	speedups := make([][]float64, len(targets))
+
+	for _, name := range base.Order {
+		b := base.Tests[name]
+		if b.Failed || b.Mean <= 0 {
+			continue
+		}
+		row := &Row{Name: name, Base: b.Mean, Cells: make([]*Cell, len(targets))}
+		matched := false
+		for i, tr := range targets {
+			t, ok := tr.Tests[name]
+			if !ok || t.Failed || t.Mean <= 0 {
+				continue
+			}
+			c := &Cell{
+				Mean:    t.Mean,
+				Speedup: b.Mean / t.Mean,
+				PValue:  WelchTest(b.Samples, t.Samples),
+			}
+			if 1/c.Speedup-1 > opt.Threshold {
+				c.Regression = c.Significant(opt.Alpha)
+				c.Unsure = math.IsNaN(c.PValue)
+			}
+			row.Cells[i] = c
+			speedups[i] = append(speedups[i], c.Speedup)
+			cmp.Matched[i]++
+			matched = true
+		}
+		if matched {
+			cmp.Rows = append(cmp.Rows, row)
+		}
+	}
+
+	for i := range targets {
+		cmp.GeoMean[i] = GeoMean(speedups[i])
+	}
+
+	return cmp
+}
+
+// SortBy orders rows by speedup of the target from the slowest to the fastest
+func (cmp *Comparison) SortBy(target int) {
+	key := func(r *Row) float64 {
+		if c := r.Cells[target]; c != nil {
+			return c.Speedup
+		}
+		return math.Inf(1)
+	}
+	sort.SliceStable(cmp.Rows, func(i, j int) bool {
+		return key(cmp.Rows[i]) < key(cmp.Rows[j])
+	})
+}
+
+func (cmp *Comparison) Regressions() []*Row {
+	return cmp.filter(func(c *Cell) bool { return c.Regression })
+}
+
+// Unsure returns rows slower than threshold without enough samples
+// to be reported as regressions
+func (cmp *Comparison) Unsure() []*Row {
+	return cmp.filter(func(c *Cell) bool { return c.Unsure })
+}
+
+func (cmp *Comparison) filter(f func(*Cell) bool) []*Row {
+	var ret []*Row
+	for _, r := range cmp.Rows {
+		for _, c := range r.Cells {
+			if c != nil && f(c) {
+				ret = append(ret, r)
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,59 @@
+package compare
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testRun(label string, tests map[string][]float64) *Run {
+	r := &Run{Label: label, Tests: make(map[string]*Test)}
+	for name, samples := range tests {
+		r.Tests[name] = &Test{Name: name, Samples: samples, Mean: mean(samples)}
+		r.Order = append(r.Order, name)
+	}
+	return r
+}
+
+func TestCompare(t *testing.T) {
+	base := testRun("classic", map[string][]float64{
+		"slow":   {100, 101, 99, 100, 100},
+		"noisy":  {100, 60, 140, 100, 100},
+		"single": {100},
+		"fast":   {100, 101, 99, 100, 100},
+	})
+	target := testRun("sputnik", map[string][]float64{
+		"slow":   {120, 121, 119, 120, 120},
+		"noisy":  {120, 60, 180, 120, 120},
+		"single": {200},
+		"fast":   {50, 51, 49, 50, 50},
+	})
+	cmp := Compare(DefaultOptions, base, target)
+	cells := make(map[string]*Cell)
+	for _, r := range cmp.Rows {
+		cells[r.Name] = r.Cells[0]
+	}
+	if c := cells["slow"]; !c.Regression || c.Unsure {
+		t.Errorf("significant slowdown is not a regression: %+v", c)
+	}
+	if c := cells["noisy"]; c.Regression || c.Unsure {
+		t.Errorf("noisy slowdown is a regression: %+v", c)
+	}
+	if c := cells["single"]; c.Regression || !c.Unsure {
+		t.Errorf("slowdown without samples is a regression: %+v", c)
+	}
+	if c := cells["fast"]; c.Regression || c.Unsure || c.Speedup != 2 {
+		t.Errorf("speedup is a regression: %+v", c)
+	}
+	if len(cmp.Regressions()) != 1 || len(cmp.Unsure()) != 1 {
+		t.Errorf("wrong regressions %d and unsure %d", len(cmp.Regressions()), len(cmp.Unsure()))
+	}
+
+	var md bytes.Buffer
+	cmp.WriteMarkdown(&md)
+	for _, s := range []string{"20% slower !", "100% slower ?", "100% faster", "| single | 100ns | 200ns | 100% slower ? | - |"} {
+		if !strings.Contains(md.String(), s) {
+			t.Errorf("%q is not in report\n%s", s, md.String())
+		}
+	}
+}
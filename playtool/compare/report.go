@@ -0,0 +1,118 @@
+package compare
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func duration(ns float64) string {
+	return time.Duration(ns).String()
+}
+
+// percent formats speedup the same way as benchmarks/vm/README.md does
+func percent(speedup float64) string {
+	if speedup >= 1 {
+		return fmt.Sprintf("%d%% faster", int(speedup*100-100))
+	}
+	return fmt.Sprintf("%d%% slower", int(100/speedup-100))
+}
+
+func pvalue(p float64) string {
+	if math.IsNaN(p) {
+		return "-"
+	}
+	return fmt.Sprintf("%.3f", p)
+}
+
+func (cmp *Comparison) header() []string {
+	h := []string{"Name", title(cmp.Base.Label)}
+	for _, t := range cmp.Targets {
+		l := title(t.Label)
+		h = append(h, l, l+" vs "+title(cmp.Base.Label), "p")
+	}
+	return h
+}
+
+func (cmp *Comparison) cells(r *Row) []string {
+	a := []string{r.Name, duration(r.Base)}
+	for _, c := range r.Cells {
+		if c == nil {
+			a = append(a, "", "", "")
+			continue
+		}
+		s := percent(c.Speedup)
+		if c.Regression {
+			s += " !"
+		} else if c.Unsure {
+			s += " ?"
+		}
+		a = append(a, duration(c.Mean), s, pvalue(c.PValue))
+	}
+	return a
+}
+
+func (cmp *Comparison) summary() []string {
+	var a []string
+	for i, t := range cmp.Targets {
+		a = append(a, fmt.Sprintf(
+			"%s vs %s: geometric mean %s over %d tests",
+			title(t.Label), title(cmp.Base.Label), percent(cmp.GeoMean[i]), cmp.Matched[i]))
+	}
+	a = append(a, fmt.Sprintf(
+		"%d regressions slower more than %.0f%% with p < %.2f (marked by !)",
+		len(cmp.Regressions()), cmp.Threshold*100, cmp.Alpha))
+	if n := len(cmp.Unsure()); n != 0 {
+		a = append(a, fmt.Sprintf(
+			"%d slower more than %.0f%% without enough samples to tell (marked by ?)",
+			n, cmp.Threshold*100))
+	}
+	return a
+}
+
+func (cmp *Comparison) WriteMarkdown(wr io.Writer) {
+	for _, s := range cmp.summary() {
+		fmt.Fprintf(wr, "* %s\n", s)
+	}
+	fmt.Fprintln(wr)
+	h := cmp.header()
+	fmt.Fprintf(wr, "| %s |\n", strings.Join(h, " | "))
+	fmt.Fprintf(wr, "|%s\n", strings.Repeat("---|", len(h)))
+	for _, r := range cmp.Rows {
+		fmt.Fprintf(wr, "| %s |\n", strings.Join(cmp.cells(r), " | "))
+	}
+}
+
+func (cmp *Comparison) WriteHTML(wr io.Writer) {
+	fmt.Fprintln(wr, "<ul>")
+	for _, s := range cmp.summary() {
+		fmt.Fprintf(wr, "<li>%s</li>\n", html.EscapeString(s))
+	}
+	fmt.Fprintln(wr, "</ul>")
+	fmt.Fprintln(wr, "<table border=\"1\">")
+	fmt.Fprint(wr, "<thead><tr>")
+	for _, s := range cmp.header() {
+		fmt.Fprintf(wr, "<th>%s</th>", html.EscapeString(s))
+	}
+	fmt.Fprintln(wr, "</tr></thead>")
+	fmt.Fprintln(wr, "<tbody>")
+	for _, r := range cmp.Rows {
+		fmt.Fprint(wr, "<tr>")
+		for _, s := range cmp.cells(r) {
+			fmt.Fprintf(wr, "<td>%s</td>", html.EscapeString(s))
+		}
+		fmt.Fprintln(wr, "</tr>")
+	}
+	fmt.Fprintln(wr, "</tbody>")
+	fmt.Fprintln(wr, "</table>")
+}
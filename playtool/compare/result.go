@@ -0,0 +1,148 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Result is the node of benchmark results tree written by T.WriteJsonResult,
+// times are in nanoseconds
+type Result struct {
+	Label    string      `json:"label"`
+	Total    int64       `json:"total"`
+	Active   int64       `json:"active"`
+	Count    int64       `json:"count"`
+	Error    interface{} `json:"error"`
+	Samples  []int64     `json:"samples,omitempty"`
+	Allocs   uint64      `json:"allocs,omitempty"` // count of allocations in one sample
+	Bytes    uint64      `json:"bytes,omitempty"`  // allocated bytes in one sample
+	Children []*Result   `json:"children,omitempty"`
+}
+
+func (r *Result) Failed() bool {
+	switch e := r.Error.(type) {
+	case nil:
+		return false
+	case string:
+		return e != ""
+	}
+	return true
+}
+
+// Mean returns average active time of one sample
+func (r *Result) Mean() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+	return float64(r.Active) / float64(r.Count)
+}
+
+// Run is the set of benchmark results loaded from one or several files,
+// several files of the same run are treated as repeated runs and gives
+// samples when results do not have them
+type Run struct {
+	Label string
+	Tests map[string]*Test
+	Order []string
+}
+
+type Test struct {
+	Name    string
+	Mean    float64
+	Samples []float64
+	Failed  bool
+}
+
+// LoadRun loads results from comma separated list of files, the label
+// is the name of directory containing the first file like 'classic' or 'sputnik'
+func LoadRun(files string) (*Run, error) {
+	var results []*Result
+	fl := strings.Split(files, ",")
+	for _, fn := range fl {
+		r, err := LoadResult(fn)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	label := filepath.Base(filepath.Dir(fl[0]))
+	if label == "." || label == string(filepath.Separator) {
+		label = strings.TrimSuffix(filepath.Base(fl[0]), filepath.Ext(fl[0]))
+	}
+	return newRun(label, results), nil
+}
+
+func LoadResult(fn string) (*Result, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("malformed benchmark result %s: %v", fn, err)
+	}
+	if raw, ok := m["results"]; ok {
+		b = raw
+	}
+	r := &Result{}
+	if err = json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("malformed benchmark result %s: %v", fn, err)
+	}
+	return r, nil
+}
+
+// Phase keeps only tests of the phase written by playtool.Phases
+// like 'Memory/mem32kb/execute' and strips the phase from names
+func (run *Run) Phase(phase string) *Run {
+	suffix := "/" + phase
+	r := &Run{Label: run.Label, Tests: make(map[string]*Test)}
+	for _, name := range run.Order {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		t := *run.Tests[name]
+		t.Name = strings.TrimSuffix(name, suffix)
+		r.Tests[t.Name] = &t
+		r.Order = append(r.Order, t.Name)
+	}
+	return r
+}
+
+func leafs(r *Result, f func(*Result)) {
+	if len(r.Children) == 0 {
+		f(r)
+		return
+	}
+	for _, c := range r.Children {
+		leafs(c, f)
+	}
+}
+
+func newRun(label string, results []*Result) *Run {
+	run := &Run{Label: label, Tests: make(map[string]*Test)}
+	for _, r := range results {
+		leafs(r, func(x *Result) {
+			t, ok := run.Tests[x.Label]
+			if !ok {
+				t = &Test{Name: x.Label}
+				run.Tests[x.Label] = t
+				run.Order = append(run.Order, x.Label)
+			}
+			t.Failed = t.Failed || x.Failed()
+			if len(x.Samples) != 0 {
+				for _, s := range x.Samples {
+					t.Samples = append(t.Samples, float64(s))
+				}
+			} else if x.Count != 0 {
+				t.Samples = append(t.Samples, x.Mean())
+			}
+		})
+	}
+	for _, t := range run.Tests {
+		t.Mean = mean(t.Samples)
+	}
+	return run
+}
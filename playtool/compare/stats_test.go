@@ -0,0 +1,59 @@
+package compare
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBetaInc(t *testing.T) {
+	for _, c := range []struct {
+		a, b, x, want float64
+	}{
+		{1, 1, 0.3, 0.3},
+		{3, 1, 0.4, 0.064},                  // x^a
+		{1, 4, 0.2, 1 - math.Pow(0.8, 4)},   // 1-(1-x)^b
+		{2, 2, 0.25, 3*0.0625 - 2*0.015625}, // 3x^2-2x^3
+		{7.5, 7.5, 0.5, 0.5},
+		{0.5, 0.5, 0.5, 0.5},
+		{2, 3, 0, 0},
+		{2, 3, 1, 1},
+	} {
+		if have := betaInc(c.a, c.b, c.x); math.Abs(have-c.want) > 1e-12 {
+			t.Errorf("I_%v(%v,%v) = %v, want %v", c.x, c.a, c.b, have, c.want)
+		}
+	}
+}
+
+func TestWelchTest(t *testing.T) {
+	// R: t.test(extra ~ group, data = sleep)
+	// t = -1.8608, df = 17.776, p-value = 0.07939
+	sleep1 := []float64{0.7, -1.6, -0.2, -1.2, -0.1, 3.4, 3.7, 0.8, 0.0, 2.0}
+	sleep2 := []float64{1.9, 0.8, 1.1, 0.1, -0.1, 4.4, 5.5, 1.6, 4.6, 3.4}
+	if p := WelchTest(sleep1, sleep2); math.Abs(p-0.07939) > 1e-5 {
+		t.Errorf("sleep p-value %v", p)
+	}
+	if p := WelchTest(sleep2, sleep1); math.Abs(p-0.07939) > 1e-5 {
+		t.Errorf("symmetric p-value %v", p)
+	}
+
+	// t = -sqrt(2) with df = 2 gives p = 1 - sqrt(2)/2
+	if p := WelchTest([]float64{0, 2}, []float64{2, 4}); math.Abs(p-(1-math.Sqrt2/2)) > 1e-12 {
+		t.Errorf("df 2 p-value %v", p)
+	}
+
+	if p := WelchTest([]float64{1, 1}, []float64{1, 1}); p != 1 {
+		t.Errorf("equal constant samples p-value %v", p)
+	}
+	if p := WelchTest([]float64{1, 1}, []float64{2, 2}); p != 0 {
+		t.Errorf("different constant samples p-value %v", p)
+	}
+	if p := WelchTest([]float64{1}, []float64{2, 3}); !math.IsNaN(p) {
+		t.Errorf("one sample p-value %v", p)
+	}
+}
+
+func TestGeoMean(t *testing.T) {
+	if g := GeoMean([]float64{1, 4, 16}); math.Abs(g-4) > 1e-12 {
+		t.Errorf("geometric mean %v", g)
+	}
+}
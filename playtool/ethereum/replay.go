@@ -0,0 +1,421 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/sudachen/playground/branch/ethereum/chain"
+	ethvm "github.com/sudachen/playground/branch/ethereum/vm"
+	"github.com/sudachen/playground/libeth"
+)
+
+const DefaultReplayBatchLen = 1000
+
+type ReplayOptions struct {
+	Chain     *chain.Options
+	StateDir  string            // replay state database, it's kept between runs
+	Snapshot  uint64            // replay starts from the state of this block if state database is empty
+	Last      uint64            // the last replayed block, 0 means the last block of the chain
+	BatchLen  int               // count of blocks between progress reports
+	ReportDir string            // divergence reports directory, current by default
+	NewVM     func() libeth.VM1 // go-ethereum VM by default
+	Metrics   *chain.Metrics    // per block metrics are collected if not nil
+
+	// other VMs can be used via libeth.NewVM1, storage values are read from StateDB
+	// by key when VM asks them, so they replay from a snapshot as well
+}
+
+// DivergenceError is returned when the replayed block is not matched
+// to the same in the chain, the report contains details
+type DivergenceError struct {
+	Number uint64
+	Report string
+	Reason string
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("block %d diverged: %s, see %s", e.Number, e.Reason, e.Report)
+}
+
+type AccountState struct {
+	Balance     *big.Int    `json:"balance"`
+	Nonce       uint64      `json:"nonce"`
+	CodeHash    common.Hash `json:"codeHash"`
+	StorageRoot common.Hash `json:"storageRoot"`
+}
+
+// AccountDiff is the state of account touched by the diverged block,
+// nil state means the account does not exist
+type AccountDiff struct {
+	Address  common.Address `json:"address"`
+	Parent   *AccountState  `json:"parent"`
+	Actual   *AccountState  `json:"actual"`
+	Expected *AccountState  `json:"expected,omitempty"` // only if chain has state of the block
+}
+
+type Divergence struct {
+	Number       uint64         `json:"number"`
+	Hash         common.Hash    `json:"hash"`
+	ExpectedRoot common.Hash    `json:"expectedRoot"`
+	Root         common.Hash    `json:"root"`
+	TxIndex      int            `json:"txIndex"` // the first transaction not matched to its receipt, -1 if all are matched
+	TxHash       *common.Hash   `json:"txHash,omitempty"`
+	ExpectedGas  uint64         `json:"expectedGas,omitempty"` // cumulative gas from the receipt
+	UsedGas      uint64         `json:"usedGas,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	Accounts     []*AccountDiff `json:"accounts"`
+}
+
+type checkpoint struct {
+	Number uint64
+	Hash   common.Hash
+	Root   common.Hash
+}
+
+var checkpointKey = []byte("replay-checkpoint")
+
+func blockhashKey(n uint64) []byte {
+	k := make([]byte, 9)
+	k[0] = 'h'
+	binary.BigEndian.PutUint64(k[1:], n)
+	return k
+}
+
+type replayer struct {
+	src       chain.Reader
+	cfg       *params.ChainConfig
+	db        ethdb.Database
+	cache     state.Database
+	newVM     func() libeth.VM1
+	root      common.Hash
+	reportDir string
+	metrics   *chain.Metrics
+}
+
+func newReplayer(src chain.Reader, db ethdb.Database, newVM func() libeth.VM1) *replayer {
+	if newVM == nil {
+		newVM = ethvm.NewVM
+	}
+	return &replayer{
+		src:   src,
+		cfg:   src.Config(),
+		db:    db,
+		cache: state.NewDatabase(db),
+		newVM: newVM,
+	}
+}
+
+func (r *replayer) blockhash(n uint64) common.Hash {
+	b, err := r.db.Get(blockhashKey(n))
+	if err != nil || b == nil {
+		return common.Hash{}
+	}
+	return common.BytesToHash(b)
+}
+
+func (r *replayer) commit(b *types.Block) error {
+	if err := r.db.Put(blockhashKey(b.NumberU64()), b.Hash().Bytes()); err != nil {
+		return err
+	}
+	cp, err := rlp.EncodeToBytes(&checkpoint{b.NumberU64(), b.Hash(), r.root})
+	if err != nil {
+		return err
+	}
+	return r.db.Put(checkpointKey, cp)
+}
+
+// start copies state of the block from the chain and indexes
+// hashes of preceding blocks available for BLOCKHASH
+func (r *replayer) start(nr uint64) error {
+	b := r.src.Block(nr)
+	if b == nil {
+		return fmt.Errorf("block %d not found", nr)
+	}
+	if err := r.src.CopyState(b.Root(), r.db); err != nil {
+		return fmt.Errorf("failed to copy state of block %d: %v", nr, err)
+	}
+	from := uint64(0)
+	if nr > 256 {
+		from = nr - 256
+	}
+	for n := from; n < nr; n++ {
+		p := r.src.Block(n)
+		if p == nil {
+			return fmt.Errorf("block %d not found", n)
+		}
+		if err := r.db.Put(blockhashKey(n), p.Hash().Bytes()); err != nil {
+			return err
+		}
+	}
+	r.root = b.Root()
+	return r.commit(b)
+}
+
+// resume continues from the last committed block, it returns false
+// if the state database is empty
+func (r *replayer) resume() (uint64, bool, error) {
+	b, err := r.db.Get(checkpointKey)
+	if err != nil || b == nil {
+		return 0, false, nil
+	}
+	cp := &checkpoint{}
+	if err := rlp.DecodeBytes(b, cp); err != nil {
+		return 0, false, fmt.Errorf("malformed replay checkpoint: %v", err)
+	}
+	if blk := r.src.Block(cp.Number); blk == nil || blk.Hash() != cp.Hash {
+		return 0, false, fmt.Errorf("replay state does not match the chain at block %d", cp.Number)
+	}
+	r.root = cp.Root
+	return cp.Number, true, nil
+}
+
+// replay applies the block to the current state and commits it,
+// DivergenceError is returned if the result does not match the chain
+func (r *replayer) replay(block *chain.Block) error {
+	sdb, err := state.New(r.root, r.cache)
+	if err != nil {
+		return err
+	}
+
+	number := block.Number()
+	if r.cfg.DAOForkSupport && r.cfg.DAOForkBlock != nil && r.cfg.DAOForkBlock.Cmp(number) == 0 {
+		misc.ApplyDAOHardFork(sdb)
+	}
+
+	bi := &libeth.BlockInfo{
+		Header:    *block.Header(),
+		Blockhash: r.blockhash,
+		RuleSet:   r.src.Rules(),
+		Config:    r.cfg,
+	}
+
+	d := &Divergence{
+		Number:       block.NumberU64(),
+		Hash:         block.Hash(),
+		ExpectedRoot: block.Root(),
+		TxIndex:      -1,
+	}
+	receipts := r.src.Receipts(block.Block)
+	eip158 := r.cfg.IsEIP158(number)
+
+	// metrics do not take time if they are disabled
+	bm := r.metrics.Block(block)
+	started := bm.Now()
+
+	var usedGas uint64
+	for i, tx := range block.Transactions() {
+		sdb.Prepare(tx.Hash(), block.Hash(), i)
+		evm := r.newVM()
+		txStarted := bm.TxNow()
+		gas, _, err := evm.Execute(block.Messages[i], bi, sdb)
+		bm.Tx(i, tx, gas, txStarted)
+		usedGas += gas
+		if err != nil {
+			bm.Diverge()
+			bm.Done(usedGas, started, bm.Now())
+			d.at(i, tx, usedGas, receipts)
+			d.Error = err.Error()
+			return r.diverged(block, d, sdb, "transaction failed")
+		}
+		var root []byte
+		if r.cfg.IsByzantium(number) {
+			sdb.Finalise(true)
+		} else {
+			root = sdb.IntermediateRoot(eip158).Bytes()
+		}
+		if d.TxIndex < 0 && i < len(receipts) && !matched(receipts[i], usedGas, root) {
+			d.at(i, tx, usedGas, receipts)
+		}
+	}
+
+	executed := bm.Now()
+	header := types.CopyHeader(block.Header())
+	if _, err := r.src.Engine().Finalize(r.src.Chain(), header, sdb, block.Transactions(), block.Uncles(), nil); err != nil {
+		return err
+	}
+
+	root, err := sdb.CommitTo(r.db, eip158)
+	if err != nil {
+		return err
+	}
+	if root != block.Root() {
+		bm.Diverge()
+		bm.Done(usedGas, started, executed)
+		d.Root = root
+		return r.diverged(block, d, sdb, "state root mismatch")
+	}
+	bm.Done(usedGas, started, executed)
+
+	r.root = root
+	return r.commit(block.Block)
+}
+
+func matched(rc *types.Receipt, usedGas uint64, root []byte) bool {
+	if rc.CumulativeGasUsed != usedGas {
+		return false
+	}
+	return root == nil || len(rc.PostState) != len(root) || common.BytesToHash(rc.PostState) == common.BytesToHash(root)
+}
+
+func (d *Divergence) at(i int, tx *types.Transaction, usedGas uint64, receipts types.Receipts) {
+	h := tx.Hash()
+	d.TxIndex = i
+	d.TxHash = &h
+	d.UsedGas = usedGas
+	if i < len(receipts) {
+		d.ExpectedGas = receipts[i].CumulativeGasUsed
+	}
+}
+
+func accountState(st *state.StateDB, a common.Address) *AccountState {
+	if st == nil || !st.Exist(a) {
+		return nil
+	}
+	s := &AccountState{
+		Balance:  st.GetBalance(a),
+		Nonce:    st.GetNonce(a),
+		CodeHash: st.GetCodeHash(a),
+	}
+	if t := st.StorageTrie(a); t != nil {
+		s.StorageRoot = t.Hash()
+	}
+	return s
+}
+
+// touched returns addresses of accounts which could be changed by the block
+func (r *replayer) touched(block *chain.Block, sdb *state.StateDB) []common.Address {
+	var a []common.Address
+	known := make(map[common.Address]bool)
+	add := func(x common.Address) {
+		if !known[x] {
+			known[x] = true
+			a = append(a, x)
+		}
+	}
+	add(block.Coinbase())
+	for _, u := range block.Uncles() {
+		add(u.Coinbase)
+	}
+	for i, tx := range block.Transactions() {
+		from := block.Messages[i].From()
+		add(from)
+		if tx.To() != nil {
+			add(*tx.To())
+		} else {
+			add(crypto.CreateAddress(from, tx.Nonce()))
+		}
+		for _, l := range sdb.GetLogs(tx.Hash()) {
+			add(l.Address)
+		}
+	}
+	return a
+}
+
+func (r *replayer) diverged(block *chain.Block, d *Divergence, sdb *state.StateDB, reason string) error {
+	parent, _ := state.New(r.root, r.cache)
+	expected, _ := r.src.StateAt(block.Root())
+	for _, a := range r.touched(block, sdb) {
+		ad := &AccountDiff{
+			Address: a,
+			Parent:  accountState(parent, a),
+			Actual:  accountState(sdb, a),
+		}
+		if expected != nil {
+			ad.Expected = accountState(expected, a)
+		}
+		d.Accounts = append(d.Accounts, ad)
+	}
+
+	fn := filepath.Join(r.reportDir, fmt.Sprintf("divergence.%d.json", d.Number))
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(fn, b, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("block %d diverged: %s, failed to write report: %v", d.Number, reason, err)
+	}
+	return &DivergenceError{d.Number, fn, reason}
+}
+
+// Replay replays blocks of the chain over the persistent state database,
+// it continues from the last committed block if the database is not empty
+func Replay(o *ReplayOptions, ctx context.Context) error {
+	src, err := chain.Open(o.Chain)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	db, err := chain.OpenDb(o.Chain, o.StateDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	r := newReplayer(src, db, o.NewVM)
+	r.reportDir = o.ReportDir
+	r.metrics = o.Metrics
+
+	first, ok, err := r.resume()
+	if err != nil {
+		return err
+	}
+	if ok {
+		fmt.Fprintf(os.Stderr, "resume after block %v\n", first)
+	} else {
+		first = o.Snapshot
+		if a, ok := src.(*chain.Archive); ok && first == 0 {
+			// archive has only the state of its parent block
+			first = a.Parent()
+		}
+		fmt.Fprintf(os.Stderr, "start from state of block %v\n", first)
+		if err := r.start(first); err != nil {
+			return err
+		}
+	}
+
+	last := o.Last
+	if last == 0 {
+		last = src.Last()
+	}
+	if first >= last {
+		return nil
+	}
+
+	batchLen := o.BatchLen
+	if batchLen == 0 {
+		batchLen = DefaultReplayBatchLen
+	}
+
+	replayed := first
+	err = chain.Process(src.Export(ctx, first+1, last), batchLen, ctx,
+		func(bs []*chain.Block, ctx context.Context) error {
+			for _, block := range bs {
+				if err := r.replay(block); err != nil {
+					return err
+				}
+				replayed = block.NumberU64()
+			}
+			fmt.Fprintf(os.Stderr, "replayed blocks [%v-%v]\n",
+				bs[0].NumberU64(), bs[len(bs)-1].NumberU64())
+			return nil
+		})
+	if err == nil && replayed != last {
+		err = fmt.Errorf("replay is stopped on block %v before block %v", replayed, last)
+	}
+	return err
+}
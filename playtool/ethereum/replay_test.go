@@ -0,0 +1,177 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/sudachen/playground/branch/ethereum/chain"
+	ethvm "github.com/sudachen/playground/branch/ethereum/vm"
+	"github.com/sudachen/playground/libeth"
+)
+
+func testReplayOptions(t *testing.T) (*ReplayOptions, func()) {
+	dir, err := ioutil.TempDir("", "replay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ReplayOptions{
+		Chain: &chain.Options{
+			Synthetic: &chain.Synthetic{Seed: 1, Blocks: 12, TxsPerBlock: 4, Transfers: 1, Tokens: 1, Storage: 1, Creates: 1, Calls: 1},
+		},
+		StateDir:  filepath.Join(dir, "state"),
+		ReportDir: dir,
+		BatchLen:  5,
+		Metrics:   &chain.Metrics{},
+	}, func() { os.RemoveAll(dir) }
+}
+
+func replayedBlocks(m *chain.Metrics) []uint64 {
+	var a []uint64
+	for _, b := range m.Blocks() {
+		a = append(a, b.Number)
+	}
+	return a
+}
+
+func TestReplay(t *testing.T) {
+	o, remove := testReplayOptions(t)
+	defer remove()
+
+	o.Last = 5
+	if err := Replay(o, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a := replayedBlocks(o.Metrics); len(a) != 5 || a[0] != 1 || a[4] != 5 {
+		t.Fatalf("wrong replayed blocks %v", a)
+	}
+
+	// the same seed gives the same chain, so replay is resumed
+	o.Last = 0
+	o.Metrics = &chain.Metrics{}
+	if err := Replay(o, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a := replayedBlocks(o.Metrics); len(a) != 7 || a[0] != 6 || a[6] != 12 {
+		t.Fatalf("wrong resumed blocks %v", a)
+	}
+
+	// nothing to replay
+	o.Metrics = &chain.Metrics{}
+	if err := Replay(o, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a := replayedBlocks(o.Metrics); len(a) != 0 {
+		t.Fatalf("blocks are replayed twice %v", a)
+	}
+
+	// the state of other chain is not resumed
+	o.Chain.Synthetic.Seed = 2
+	if err := Replay(o, context.Background()); err == nil {
+		t.Fatal("replay of other chain is resumed")
+	}
+}
+
+func TestReplayFailedBlock(t *testing.T) {
+	o, remove := testReplayOptions(t)
+	defer remove()
+
+	// the chain has 12 blocks, so block 13 is not found
+	o.Last = 20
+	if err := Replay(o, context.Background()); err == nil {
+		t.Fatal("replay of missing blocks succeeded")
+	}
+	if a := replayedBlocks(o.Metrics); len(a) != 12 {
+		t.Fatalf("wrong replayed blocks %v", a)
+	}
+
+	// blocks of the synthetic archive are sealed by the faker
+	dir, err := ioutil.TempDir("", "replay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = Replay(&ReplayOptions{
+		Chain: &chain.Options{
+			Archive:   filepath.Join("..", "..", "testdata", "chain", "synthetic-1-9-24.rlp.gz"),
+			VerifyPow: true,
+		},
+		StateDir:  filepath.Join(dir, "state"),
+		ReportDir: dir,
+	}, context.Background())
+	if err == nil {
+		t.Fatal("blocks with invalid seals are replayed")
+	}
+}
+
+// failingVM fails on the transaction or gives away balance to the coinbase
+type failingVM struct {
+	number  uint64
+	fail    bool
+	execute libeth.VM1
+}
+
+func (f *failingVM) Execute(msg core.Message, bi *libeth.BlockInfo, sdb vm.StateDB) (uint64, bool, error) {
+	if bi.Number.Uint64() == f.number {
+		if f.fail {
+			return 0, false, errors.New("failed")
+		}
+		sdb.AddBalance(bi.Coinbase, big.NewInt(1))
+	}
+	return f.execute.Execute(msg, bi, sdb)
+}
+
+func TestReplayDivergence(t *testing.T) {
+	for _, fail := range []bool{true, false} {
+		o, remove := testReplayOptions(t)
+		o.NewVM = func() libeth.VM1 { return &failingVM{7, fail, ethvm.NewVM()} }
+
+		err := Replay(o, context.Background())
+		de, ok := err.(*DivergenceError)
+		if !ok {
+			t.Fatalf("wrong error %v", err)
+		}
+		if de.Number != 7 {
+			t.Errorf("wrong diverged block %d", de.Number)
+		}
+		b, err := ioutil.ReadFile(de.Report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &Divergence{}
+		if err := json.Unmarshal(b, d); err != nil {
+			t.Fatal(err)
+		}
+		if d.Number != 7 || len(d.Accounts) == 0 {
+			t.Errorf("wrong report %s", b)
+		}
+		if fail && (d.TxIndex != 0 || d.Error != "failed") {
+			t.Errorf("wrong failed transaction in report %s", b)
+		}
+		if !fail && (d.Error != "" || d.Root == (common.Hash{}) || d.Root == d.ExpectedRoot) {
+			t.Errorf("wrong state mismatch in report %s", b)
+		}
+		if bs := o.Metrics.Blocks(); len(bs) == 0 || bs[len(bs)-1].Number != 7 || !bs[len(bs)-1].Diverged {
+			t.Error("diverged block is not in metrics")
+		}
+
+		// diverged block is not committed, so replay is resumed from the previous one
+		o.NewVM = nil
+		o.Metrics = &chain.Metrics{}
+		if err := Replay(o, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if a := replayedBlocks(o.Metrics); len(a) == 0 || a[0] != 7 {
+			t.Errorf("wrong resumed blocks %v", a)
+		}
+		remove()
+	}
+}
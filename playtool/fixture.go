@@ -0,0 +1,373 @@
+package playtool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sudachen/playground/libeth"
+)
+
+// FixtureError names JSON path of the malformed value, so the error looks like
+// 'StateExample/add11: pre.095e7baea6a6c7c4c2dfeb977efac326af552d87.balance: malformed value "0xzz"'
+type FixtureError struct {
+	Path []string
+	Err  error
+}
+
+func (e *FixtureError) Error() string {
+	return fmt.Sprintf("%s: %v", strings.Join(e.Path, "."), e.Err)
+}
+
+var missingField = errors.New("field does not exist")
+
+func atPath(name string, err error) error {
+	if fe, ok := err.(*FixtureError); ok {
+		fe.Path = append([]string{name}, fe.Path...)
+		return fe
+	}
+	return &FixtureError{[]string{name}, err}
+}
+
+type field struct {
+	name     string
+	value    interface{}
+	optional bool
+}
+
+func decodeFields(b []byte, list ...field) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("malformed value, object is expected: %v", err)
+	}
+	for _, x := range list {
+		raw, ok := m[x.name]
+		if !ok {
+			if x.optional {
+				continue
+			}
+			return atPath(x.name, missingField)
+		}
+		if err := json.Unmarshal(raw, x.value); err != nil {
+			return atPath(x.name, err)
+		}
+	}
+	return nil
+}
+
+type Storage map[libeth.Hash]libeth.Hash
+
+func (s *Storage) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("malformed value, object is expected: %v", err)
+	}
+	*s = make(Storage, len(m))
+	for k, raw := range m {
+		key, err := decodeHash(k)
+		if err != nil {
+			return atPath(k, err)
+		}
+		var v HexHash
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return atPath(k, err)
+		}
+		(*s)[key] = libeth.Hash(v)
+	}
+	return nil
+}
+
+type Account struct {
+	Balance HexBig
+	Nonce   HexUint64
+	Code    HexBytes
+	Storage Storage
+}
+
+func (a *Account) UnmarshalJSON(b []byte) error {
+	a.Code = HexBytes{}
+	return decodeFields(b,
+		field{"balance", &a.Balance, false},
+		field{"nonce", &a.Nonce, false},
+		field{"code", &a.Code, true},
+		field{"storage", &a.Storage, false},
+	)
+}
+
+type Accounts map[libeth.Address]*Account
+
+func (accs *Accounts) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("malformed accounts set: %v", err)
+	}
+	*accs = make(Accounts, len(m))
+	for k, raw := range m {
+		addr, err := decodeAddress(k)
+		if err != nil {
+			return atPath(k, err)
+		}
+		acc := &Account{}
+		if err := json.Unmarshal(raw, acc); err != nil {
+			return atPath(k, err)
+		}
+		(*accs)[addr] = acc
+	}
+	return nil
+}
+
+type Log struct {
+	Address HexAddress
+	Bloom   HexBytes
+	Data    HexBytes
+	Topics  []HexHash
+}
+
+func (l *Log) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"address", &l.Address, false},
+		field{"bloom", &l.Bloom, true},
+		field{"data", &l.Data, false},
+		field{"topics", &l.Topics, true},
+	)
+}
+
+type Env struct {
+	Coinbase     HexAddress
+	Difficulty   HexBig
+	GasLimit     HexBig
+	Number       HexBig
+	Timestamp    HexBig
+	PreviousHash HexHash
+}
+
+func (e *Env) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"currentCoinbase", &e.Coinbase, false},
+		field{"currentDifficulty", &e.Difficulty, false},
+		field{"currentGasLimit", &e.GasLimit, false},
+		field{"currentNumber", &e.Number, false},
+		field{"currentTimestamp", &e.Timestamp, false},
+		field{"previousHash", &e.PreviousHash, false},
+	)
+}
+
+type StateTransaction struct {
+	Data      HexBytes
+	GasLimit  HexBig
+	GasPrice  HexBig
+	Value     HexBig
+	Nonce     HexUint64
+	SecretKey HexBytes
+	To        HexAddressOpt
+	Raw       HexBytes // signed RLP of the transaction, optional
+}
+
+func (tx *StateTransaction) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"data", &tx.Data, false},
+		field{"gasLimit", &tx.GasLimit, false},
+		field{"gasPrice", &tx.GasPrice, false},
+		field{"value", &tx.Value, false},
+		field{"nonce", &tx.Nonce, false},
+		field{"secretKey", &tx.SecretKey, false},
+		field{"to", &tx.To, true},
+		field{"raw", &tx.Raw, true},
+	)
+}
+
+// StateTest is one named test from the StateTests fixture files,
+// see the commented example in playtool/classic/jsonstate.go
+type StateTest struct {
+	Env           Env
+	Logs          []*Log
+	Out           HexBytes
+	Post          Accounts
+	PostStateRoot HexHash
+	Pre           Accounts
+	Transaction   StateTransaction
+}
+
+func (t *StateTest) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"env", &t.Env, false},
+		field{"logs", &t.Logs, true},
+		field{"out", &t.Out, false},
+		field{"post", &t.Post, false},
+		field{"postStateRoot", &t.PostStateRoot, true},
+		field{"pre", &t.Pre, false},
+		field{"transaction", &t.Transaction, false},
+	)
+}
+
+type Exec struct {
+	Address  HexAddress
+	Caller   HexAddress
+	Code     HexBytes
+	Data     HexBytes
+	Gas      HexBig
+	GasPrice HexBig
+	Origin   HexAddress
+	Value    HexBig
+}
+
+func (e *Exec) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"address", &e.Address, false},
+		field{"caller", &e.Caller, false},
+		field{"code", &e.Code, false},
+		field{"data", &e.Data, false},
+		field{"gas", &e.Gas, false},
+		field{"gasPrice", &e.GasPrice, false},
+		field{"origin", &e.Origin, false},
+		field{"value", &e.Value, false},
+	)
+}
+
+type CallCreate struct {
+	Data        HexBytes
+	Destination HexAddressOpt
+	GasLimit    HexBig
+	Value       HexBig
+}
+
+func (c *CallCreate) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"data", &c.Data, false},
+		field{"destination", &c.Destination, false},
+		field{"gasLimit", &c.GasLimit, false},
+		field{"value", &c.Value, false},
+	)
+}
+
+// VMTest is one named test from the VMTests fixture files,
+// Gas, Post and CallCreates are absent when execution is expected to fail
+type VMTest struct {
+	Env         Env
+	Exec        Exec
+	CallCreates []*CallCreate
+	Gas         *HexBig
+	Logs        []*Log
+	Out         HexBytes
+	Post        Accounts
+	Pre         Accounts
+}
+
+func (t *VMTest) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"env", &t.Env, false},
+		field{"exec", &t.Exec, false},
+		field{"callcreates", &t.CallCreates, true},
+		field{"gas", &t.Gas, true},
+		field{"logs", &t.Logs, true},
+		field{"out", &t.Out, true},
+		field{"post", &t.Post, true},
+		field{"pre", &t.Pre, false},
+	)
+}
+
+type BlockHeader struct {
+	Bloom            HexBytes
+	Coinbase         HexAddress
+	Difficulty       HexBig
+	ExtraData        HexBytes
+	GasLimit         HexBig
+	GasUsed          HexBig
+	Hash             HexHash
+	MixHash          HexHash
+	Nonce            HexBytes
+	Number           HexBig
+	ParentHash       HexHash
+	ReceiptTrie      HexHash
+	StateRoot        HexHash
+	Timestamp        HexBig
+	TransactionsTrie HexHash
+	UncleHash        HexHash
+}
+
+func (h *BlockHeader) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"bloom", &h.Bloom, false},
+		field{"coinbase", &h.Coinbase, false},
+		field{"difficulty", &h.Difficulty, false},
+		field{"extraData", &h.ExtraData, false},
+		field{"gasLimit", &h.GasLimit, false},
+		field{"gasUsed", &h.GasUsed, false},
+		field{"hash", &h.Hash, false},
+		field{"mixHash", &h.MixHash, false},
+		field{"nonce", &h.Nonce, false},
+		field{"number", &h.Number, false},
+		field{"parentHash", &h.ParentHash, false},
+		field{"receiptTrie", &h.ReceiptTrie, false},
+		field{"stateRoot", &h.StateRoot, false},
+		field{"timestamp", &h.Timestamp, false},
+		field{"transactionsTrie", &h.TransactionsTrie, false},
+		field{"uncleHash", &h.UncleHash, false},
+	)
+}
+
+type BlockTransaction struct {
+	Data     HexBytes
+	GasLimit HexBig
+	GasPrice HexBig
+	Nonce    HexUint64
+	R        HexBig
+	S        HexBig
+	V        HexBig
+	To       HexAddressOpt
+	Value    HexBig
+}
+
+func (tx *BlockTransaction) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"data", &tx.Data, false},
+		field{"gasLimit", &tx.GasLimit, false},
+		field{"gasPrice", &tx.GasPrice, false},
+		field{"nonce", &tx.Nonce, false},
+		field{"r", &tx.R, false},
+		field{"s", &tx.S, false},
+		field{"v", &tx.V, false},
+		field{"to", &tx.To, true},
+		field{"value", &tx.Value, false},
+	)
+}
+
+type Block struct {
+	RLP          HexBytes
+	Header       *BlockHeader // invalid blocks have only rlp
+	Transactions []*BlockTransaction
+	UncleHeaders []*BlockHeader
+}
+
+func (blk *Block) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"rlp", &blk.RLP, false},
+		field{"blockHeader", &blk.Header, true},
+		field{"transactions", &blk.Transactions, true},
+		field{"uncleHeaders", &blk.UncleHeaders, true},
+	)
+}
+
+// BlockchainTest is one named test from the BlockchainTests fixture files
+type BlockchainTest struct {
+	Blocks        []*Block
+	Genesis       BlockHeader
+	GenesisRLP    HexBytes
+	LastBlockHash HexHash
+	Network       string
+	Pre           Accounts
+	PostState     Accounts
+}
+
+func (t *BlockchainTest) UnmarshalJSON(b []byte) error {
+	return decodeFields(b,
+		field{"blocks", &t.Blocks, false},
+		field{"genesisBlockHeader", &t.Genesis, false},
+		field{"genesisRLP", &t.GenesisRLP, true},
+		field{"lastblockhash", &t.LastBlockHash, true},
+		field{"network", &t.Network, true},
+		field{"pre", &t.Pre, false},
+		field{"postState", &t.PostState, true},
+	)
+}
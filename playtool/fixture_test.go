@@ -0,0 +1,257 @@
+package playtool
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testStateTx = `{
+	"data" : "0x6001",
+	"gasLimit" : "0x0f4240",
+	"gasPrice" : "1",
+	"value" : "100000",
+	"nonce" : "0",
+	"secretKey" : "45a915e4d060149eb4365960e6a7a45f33439309061fa4a1bdf4c2b08f9c7bc0",
+	"to" : "095e7baea6a6c7c4c2dfeb977efac326af552d87"
+}`
+
+func TestDecodeStateTransaction(t *testing.T) {
+	var tx StateTransaction
+	if err := json.Unmarshal([]byte(testStateTx), &tx); err != nil {
+		t.Fatal(err)
+	}
+	if string(tx.Data) != "\x60\x01" {
+		t.Errorf("wrong data %x", tx.Data)
+	}
+	if tx.GasLimit.Int64() != 1000000 || tx.Value.Int64() != 100000 {
+		t.Errorf("wrong numbers %v %v", tx.GasLimit, tx.Value)
+	}
+	if tx.To.Address == nil || *tx.To.Address != common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87") {
+		t.Errorf("wrong to %v", tx.To.Address)
+	}
+
+	// only empty string means contract creation
+	create := strings.Replace(testStateTx, `"095e7baea6a6c7c4c2dfeb977efac326af552d87"`, `""`, 1)
+	if err := json.Unmarshal([]byte(create), &tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.To.Address != nil {
+		t.Errorf("contract creation has address %x", *tx.To.Address)
+	}
+}
+
+func TestDecodeAccounts(t *testing.T) {
+	var accs Accounts
+	err := json.Unmarshal([]byte(`{
+		"0x095e7baea6a6c7c4c2dfeb977efac326af552d87" : {
+			"balance" : "0x0de0b6b3a7640000",
+			"code" : "",
+			"nonce" : "0x00",
+			"storage" : { "0x" : "0x01", "0x01" : "0x1234" }
+		}
+	}`), &accs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := accs[common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")]
+	if acc == nil {
+		t.Fatal("account is not decoded")
+	}
+	if acc.Balance.Uint64() != 1000000000000000000 || len(acc.Code) != 0 {
+		t.Errorf("wrong account %v %x", acc.Balance, acc.Code)
+	}
+	if acc.Storage[common.Hash{}] != common.BigToHash(common.Big1) ||
+		acc.Storage[common.BigToHash(common.Big1)] != common.HexToHash("0x1234") {
+		t.Errorf("wrong storage %v", acc.Storage)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, c := range []struct {
+		name, from, to, path string
+	}{
+		{"bad hex", `"0x6001"`, `"0x60zz"`, "data"},
+		{"odd length", `"0x6001"`, `"0x601"`, "data"},
+		{"short to", `"095e7baea6a6c7c4c2dfeb977efac326af552d87"`, `"095e7b"`, "to"},
+		{"bad to", `"095e7baea6a6c7c4c2dfeb977efac326af552d87"`, `"095e7baea6a6c7c4c2dfeb977efac326af552dxx"`, "to"},
+		{"bad number", `"100000"`, `"1e5"`, "value"},
+		{"missing field", `"nonce" : "0",`, ``, "nonce"},
+	} {
+		var tx StateTransaction
+		err := json.Unmarshal([]byte(strings.Replace(testStateTx, c.from, c.to, 1)), &tx)
+		fe, ok := err.(*FixtureError)
+		if !ok {
+			t.Errorf("%s: wrong error %v", c.name, err)
+			continue
+		}
+		if strings.Join(fe.Path, ".") != c.path {
+			t.Errorf("%s: wrong path in %v", c.name, err)
+		}
+	}
+
+	var accs Accounts
+	err := json.Unmarshal([]byte(`{"0x095e7b" : {}}`), &accs)
+	if fe, ok := err.(*FixtureError); !ok || fe.Path[0] != "0x095e7b" {
+		t.Errorf("malformed address is accepted: %v", err)
+	}
+	var st Storage
+	err = json.Unmarshal([]byte(`{"0x01" : "0x0g"}`), &st)
+	if fe, ok := err.(*FixtureError); !ok || fe.Path[0] != "0x01" {
+		t.Errorf("malformed storage value is accepted: %v", err)
+	}
+	err = json.Unmarshal([]byte(`{"0x`+strings.Repeat("00", 33)+`" : "0x01"}`), &st)
+	if _, ok := err.(*FixtureError); !ok {
+		t.Errorf("too long storage key is accepted: %v", err)
+	}
+}
+
+const testVMTest = `{
+	"env" : {
+		"currentCoinbase" : "2adc25665018aa1fe0e6bc666dac8fc2697ff9ba",
+		"currentDifficulty" : "0x0100",
+		"currentGasLimit" : "0x0f4240",
+		"currentNumber" : "0x00",
+		"currentTimestamp" : "0x01",
+		"previousHash" : "5e20a0453cecd065ea59c37ac63e079ee08998b6045136a8ce6635c7912ec0b6"
+	},
+	"exec" : {
+		"address" : "0x0f572e5295c57f15886f9b263e2f6d2d6c7b5ec6",
+		"caller" : "cd1722f3947def4cf144679da39c4c32bdc35681",
+		"code" : "0x6001600101600055",
+		"data" : "0x",
+		"gas" : "0x0186a0",
+		"gasPrice" : "0x5af3107a4000",
+		"origin" : "cd1722f3947def4cf144679da39c4c32bdc35681",
+		"value" : "0x0de0b6b3a7640000"
+	},
+	"callcreates" : [ {
+		"data" : "0x",
+		"destination" : "",
+		"gasLimit" : "0x0186a0",
+		"value" : "0x01"
+	} ],
+	"gas" : "0x013874",
+	"pre" : {
+		"0x0f572e5295c57f15886f9b263e2f6d2d6c7b5ec6" : {
+			"balance" : "0x0de0b6b3a7640000",
+			"code" : "0x6001600101600055",
+			"nonce" : "0x00",
+			"storage" : {}
+		}
+	}
+}`
+
+func TestDecodeVMTest(t *testing.T) {
+	var vt VMTest
+	if err := json.Unmarshal([]byte(testVMTest), &vt); err != nil {
+		t.Fatal(err)
+	}
+	if vt.Exec.Gas.Int64() != 100000 || string(vt.Exec.Code) != "\x60\x01\x60\x01\x01\x60\x00\x55" {
+		t.Errorf("wrong exec %v %x", vt.Exec.Gas, vt.Exec.Code)
+	}
+	if vt.Gas == nil || vt.Gas.Int64() != 79988 || vt.Post != nil {
+		t.Errorf("wrong result %v %v", vt.Gas, vt.Post)
+	}
+	if len(vt.CallCreates) != 1 || vt.CallCreates[0].Destination.Address != nil {
+		t.Errorf("wrong callcreates %v", vt.CallCreates)
+	}
+
+	for _, c := range []struct {
+		name, from, to, path string
+	}{
+		{"bad caller", `"caller" : "cd1722f3947def4cf144679da39c4c32bdc35681"`, `"caller" : "cd1722f3"`, "exec.caller"},
+		{"bad code", `"0x6001600101600055",
+		"data"`, `"0x600",
+		"data"`, "exec.code"},
+		{"bad callcreate", `"value" : "0x01"`, `"value" : "0x0x"`, "callcreates.value"},
+		{"missing exec", `"exec"`, `"exec_"`, "exec"},
+	} {
+		var vt VMTest
+		err := json.Unmarshal([]byte(strings.Replace(testVMTest, c.from, c.to, 1)), &vt)
+		if fe, ok := err.(*FixtureError); !ok || strings.Join(fe.Path, ".") != c.path {
+			t.Errorf("%s: wrong error %v", c.name, err)
+		}
+	}
+}
+
+const testBlockHeader = `{
+	"bloom" : "0x00",
+	"coinbase" : "8888f1f195afa192cfee860698584c030f4c9db1",
+	"difficulty" : "0x020000",
+	"extraData" : "0x",
+	"gasLimit" : "0x2fefd8",
+	"gasUsed" : "0x00",
+	"hash" : "0x5a39ed1020c04d4d84539975b893a4e7c53eab6c2965db8bc3468093a31bc5ae",
+	"mixHash" : "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+	"nonce" : "0x0102030405060708",
+	"number" : "0x00",
+	"parentHash" : "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"receiptTrie" : "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+	"stateRoot" : "0x7d883d38bc7a640dd66e5cda78cd01b52a7dc40e61f7c2ddbab7cb3ae3b8b9f2",
+	"timestamp" : "0x54c98c81",
+	"transactionsTrie" : "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+	"uncleHash" : "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+}`
+
+func testBlockchainTest() string {
+	return `{
+	"blocks" : [ {
+		"rlp" : "0xf90260",
+		"blockHeader" : ` + testBlockHeader + `,
+		"transactions" : [ {
+			"data" : "0x",
+			"gasLimit" : "0x04cb2f",
+			"gasPrice" : "0x01",
+			"nonce" : "0x00",
+			"r" : "0x98ff921201554726367d2be8c804a7ff89ccf285ebc57dff8ae4c44b9c19ac4a",
+			"s" : "0x1887321be575c8095f789dd4c743dfe42c1820f9231f98a962b210e3ac2452a3",
+			"v" : "0x1c",
+			"to" : "",
+			"value" : "0x0a"
+		} ],
+		"uncleHeaders" : [ ]
+	}, {
+		"rlp" : "0xf90261"
+	} ],
+	"genesisBlockHeader" : ` + testBlockHeader + `,
+	"genesisRLP" : "0xf901fc",
+	"lastblockhash" : "0x5a39ed1020c04d4d84539975b893a4e7c53eab6c2965db8bc3468093a31bc5ae",
+	"pre" : {}
+}`
+}
+
+func TestDecodeBlockchainTest(t *testing.T) {
+	var bt BlockchainTest
+	if err := json.Unmarshal([]byte(testBlockchainTest()), &bt); err != nil {
+		t.Fatal(err)
+	}
+	if len(bt.Blocks) != 2 || bt.Blocks[0].Header == nil || bt.Blocks[1].Header != nil {
+		t.Fatalf("wrong blocks %v", bt.Blocks)
+	}
+	tx := bt.Blocks[0].Transactions[0]
+	if tx.To.Address != nil || tx.V.Int64() != 28 || tx.Value.Int64() != 10 {
+		t.Errorf("wrong transaction %v %v %v", tx.To.Address, tx.V, tx.Value)
+	}
+	if bt.Genesis.Timestamp.Int64() != 0x54c98c81 || bt.Genesis.Coinbase != HexAddress(common.HexToAddress("8888f1f195afa192cfee860698584c030f4c9db1")) {
+		t.Errorf("wrong genesis header %v", bt.Genesis)
+	}
+
+	for _, c := range []struct {
+		name, from, to, path string
+	}{
+		{"bad genesis", `"stateRoot" : "0x7d88`, `"stateRoot" : "0x7g88`, "blocks.blockHeader.stateRoot"},
+		{"bad transaction", `"v" : "0x1c"`, `"v" : "1c"`, "blocks.transactions.v"},
+		{"long last hash", `"lastblockhash" : "0x5a39ed`, `"lastblockhash" : "0x00005a39ed`, "lastblockhash"},
+		{"missing rlp", `"rlp" : "0xf90261"`, `"rl" : "0xf90261"`, "blocks.rlp"},
+		{"missing pre", `"pre" : {}`, `"post" : {}`, "pre"},
+	} {
+		var bt BlockchainTest
+		err := json.Unmarshal([]byte(strings.Replace(testBlockchainTest(), c.from, c.to, 1)), &bt)
+		if fe, ok := err.(*FixtureError); !ok || strings.Join(fe.Path, ".") != c.path {
+			t.Errorf("%s: wrong error %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package playtool
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/sudachen/playground/libeth"
+)
+
+// Fixture values are strings holding either 0x-prefixed hex or plain decimal
+// numbers, bytes, addresses and hashes may come without 0x prefix.
+
+func unquote(b []byte) (string, error) {
+	if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' {
+		return "", fmt.Errorf("malformed value %s, string is expected", b)
+	}
+	return string(b[1 : len(b)-1]), nil
+}
+
+type HexBig struct {
+	*big.Int
+}
+
+func (v *HexBig) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	if i, ok := new(big.Int).SetString(s, 0); ok {
+		v.Int = i
+		return nil
+	}
+	return fmt.Errorf("malformed value %s", b)
+}
+
+func (v HexBig) Big() *big.Int {
+	if v.Int == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(v.Int)
+}
+
+type HexUint64 uint64
+
+func (v *HexUint64) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	if i, err := strconv.ParseUint(s, 0, 64); err == nil {
+		*v = HexUint64(i)
+		return nil
+	}
+	return fmt.Errorf("malformed value %s", b)
+}
+
+// decodeHex decodes hex string with optional 0x prefix, empty string is empty bytes
+func decodeHex(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		s = "0x" + s
+	}
+	return hexutil.Decode(s)
+}
+
+func decodeAddress(s string) (a libeth.Address, err error) {
+	b, err := decodeHex(s)
+	if err != nil {
+		return a, fmt.Errorf("malformed address %q: %v", s, err)
+	}
+	if len(b) != libeth.AddressLength {
+		return a, fmt.Errorf("malformed address %q: %d bytes instead of %d", s, len(b), libeth.AddressLength)
+	}
+	return common.BytesToAddress(b), nil
+}
+
+// decodeHash accepts shorter values, storage keys and values are numbers
+// like 0x01 which are left padded with zeros
+func decodeHash(s string) (h libeth.Hash, err error) {
+	b, err := decodeHex(s)
+	if err != nil {
+		return h, fmt.Errorf("malformed hash %q: %v", s, err)
+	}
+	if len(b) > libeth.HashLength {
+		return h, fmt.Errorf("malformed hash %q: %d bytes is longer than %d", s, len(b), libeth.HashLength)
+	}
+	return common.BytesToHash(b), nil
+}
+
+type HexBytes []byte
+
+func (v *HexBytes) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	bs, err := decodeHex(s)
+	if err != nil {
+		return fmt.Errorf("malformed value %s: %v", b, err)
+	}
+	*v = bs
+	return nil
+}
+
+type HexAddress libeth.Address
+
+func (v *HexAddress) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	a, err := decodeAddress(s)
+	if err != nil {
+		return err
+	}
+	*v = HexAddress(a)
+	return nil
+}
+
+// HexAddressOpt is an address which can be empty, like the 'to' field
+// of contract creation transaction, only empty string means no address
+type HexAddressOpt struct {
+	Address *libeth.Address
+}
+
+func (v *HexAddressOpt) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	v.Address = nil
+	if s != "" {
+		a, err := decodeAddress(s)
+		if err != nil {
+			return err
+		}
+		v.Address = &a
+	}
+	return nil
+}
+
+type HexHash libeth.Hash
+
+func (v *HexHash) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	h, err := decodeHash(s)
+	if err != nil {
+		return err
+	}
+	*v = HexHash(h)
+	return nil
+}
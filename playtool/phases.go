@@ -0,0 +1,144 @@
+package playtool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/sudachen/playground/playtool/compare"
+)
+
+type Phase int
+
+const (
+	PreState Phase = iota
+	Construct
+	Execute
+	Freeze
+	phasesCount
+)
+
+var phaseNames = [phasesCount]string{"prestate", "construct", "execute", "freeze"}
+
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+type PhaseStats struct {
+	Name    string
+	Samples [phasesCount][]int64
+	Allocs  [phasesCount]uint64 // allocations of one run of the phase
+	Bytes   [phasesCount]uint64
+
+	memStats bool
+	ms       runtime.MemStats
+}
+
+// Phases collects time and allocations of the benchmark phases per test,
+// VM comparison uses only Execute phase so it does not depend on the state
+// preparation and VM wrapping costs
+type Phases struct {
+	MemStats bool // count allocations, it needs the separate run of the test
+
+	mu    sync.Mutex
+	tests []*PhaseStats
+}
+
+func (ps *Phases) Test(name string) *PhaseStats {
+	if ps == nil {
+		return nil
+	}
+	p := &PhaseStats{Name: name, memStats: ps.MemStats}
+	ps.mu.Lock()
+	ps.tests = append(ps.tests, p)
+	ps.mu.Unlock()
+	return p
+}
+
+// CountAllocs tells whether the test needs the separate run for MeasureAllocs
+func (p *PhaseStats) CountAllocs() bool {
+	return p != nil && p.memStats
+}
+
+// Measure executes f and adds its time and allocations to the phase,
+// ReadMemStats stops the world, so it must not be used in the timed region,
+// it's safe to call it on nil
+func (p *PhaseStats) Measure(phase Phase, f func()) {
+	if p.CountAllocs() {
+		p.MeasureAllocs(phase, func() {
+			p.MeasureTime(phase, f)
+		})
+	} else {
+		p.MeasureTime(phase, f)
+	}
+}
+
+// MeasureTime executes f and adds its time to the phase, it's safe to call it on nil
+func (p *PhaseStats) MeasureTime(phase Phase, f func()) {
+	if p == nil {
+		f()
+		return
+	}
+	started := time.Now()
+	f()
+	p.Samples[phase] = append(p.Samples[phase], int64(time.Since(started)))
+}
+
+// MeasureAllocs executes f and sets allocations of the phase, it's called out of
+// the timed region, it's safe to call it on nil
+func (p *PhaseStats) MeasureAllocs(phase Phase, f func()) {
+	if !p.CountAllocs() {
+		f()
+		return
+	}
+	runtime.ReadMemStats(&p.ms)
+	mallocs, bytes := p.ms.Mallocs, p.ms.TotalAlloc
+	f()
+	runtime.ReadMemStats(&p.ms)
+	p.Allocs[phase] = p.ms.Mallocs - mallocs
+	p.Bytes[phase] = p.ms.TotalAlloc - bytes
+}
+
+// Result returns phases as benchmark results tree, the leaf is named
+// like 'Memory/mem32kb/execute', so it can be compared by benchmarks/compare
+func (ps *Phases) Result() *compare.Result {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	root := &compare.Result{Label: "."}
+	for _, p := range ps.tests {
+		r := &compare.Result{Label: p.Name}
+		for phase, samples := range p.Samples {
+			if len(samples) == 0 {
+				continue
+			}
+			x := &compare.Result{
+				Label:   p.Name + "/" + Phase(phase).String(),
+				Count:   int64(len(samples)),
+				Samples: samples,
+				Allocs:  p.Allocs[phase],
+				Bytes:   p.Bytes[phase],
+			}
+			for _, s := range samples {
+				x.Active += s
+			}
+			x.Total = x.Active
+			r.Total += x.Total
+			r.Active += x.Active
+			r.Children = append(r.Children, x)
+		}
+		root.Total += r.Total
+		root.Active += r.Active
+		root.Children = append(root.Children, r)
+	}
+	return root
+}
+
+func (ps *Phases) WriteJson(fn string) error {
+	b, err := json.MarshalIndent(ps.Result(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, b, 0644)
+}
@@ -0,0 +1,48 @@
+package playtool
+
+import (
+	"testing"
+)
+
+var phasesSink []byte
+
+func TestPhases(t *testing.T) {
+	ps := &Phases{MemStats: true}
+	p := ps.Test("G/a")
+	if !p.CountAllocs() {
+		t.Fatal("allocations are not counted")
+	}
+	alloc := func() { phasesSink = make([]byte, 1024) }
+
+	p.MeasureAllocs(Execute, alloc)
+	if p.Allocs[Execute] == 0 || p.Bytes[Execute] < 1024 || len(p.Samples[Execute]) != 0 {
+		t.Errorf("wrong allocations %d %d %v", p.Allocs[Execute], p.Bytes[Execute], p.Samples[Execute])
+	}
+	allocs := p.Allocs[Execute]
+	for i := 0; i < 3; i++ {
+		p.MeasureTime(Execute, alloc)
+	}
+	if p.Allocs[Execute] != allocs || len(p.Samples[Execute]) != 3 {
+		t.Errorf("timed runs count allocations %d %v", p.Allocs[Execute], p.Samples[Execute])
+	}
+	p.Measure(PreState, alloc)
+	if p.Allocs[PreState] == 0 || len(p.Samples[PreState]) != 1 {
+		t.Errorf("wrong prestate %d %v", p.Allocs[PreState], p.Samples[PreState])
+	}
+
+	r := ps.Result().Children[0]
+	if len(r.Children) != 2 || r.Children[1].Label != "G/a/execute" || r.Children[1].Count != 3 {
+		t.Errorf("wrong result %+v", r.Children)
+	}
+
+	// nil phases only execute functions
+	var none *Phases
+	n := none.Test("G/b")
+	called := 0
+	n.Measure(Execute, func() { called++ })
+	n.MeasureTime(Execute, func() { called++ })
+	n.MeasureAllocs(Execute, func() { called++ })
+	if n.CountAllocs() || called != 3 {
+		t.Errorf("nil phases are broken")
+	}
+}
@@ -0,0 +1,194 @@
+package playtool
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+
+	"github.com/google/pprof/profile"
+	"github.com/sudachen/benchmark/ppftool"
+)
+
+// Profile captures CPU and heap profiles of every benchmark test or group,
+// it can't be used together with the whole benchmark CPU profiling (--pprof)
+// because Go runtime has only one CPU profiler
+type Profile struct {
+	Dir   string   // directory for .pprof files, current by default
+	CPU   bool     // write <test>.cpu.pprof
+	Heap  bool     // write <test>.heap.pprof, only allocations made by the test are there
+	Group bool     // profile test groups instead of tests
+	Top   int      // count of the top CPU consumers attached to the entry, 0 means no top
+	Hide  []string // regexps of functions hidden from the top like "runtime\\."
+
+	mu      sync.Mutex
+	entries []*ProfileEntry
+}
+
+type ProfileEntry struct {
+	Label string       `json:"label"`
+	CPU   string       `json:"cpu,omitempty"`
+	Heap  string       `json:"heap,omitempty"`
+	Top   ppftool.Rows `json:"top,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+func profileFileName(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, label)
+}
+
+func (p *Profile) path(label, kind string) string {
+	return filepath.Join(p.Dir, profileFileName(label)+"."+kind+".pprof")
+}
+
+// Capture executes f under profiling, profiling failures are reported in the
+// entry and do not fail the test
+func (p *Profile) Capture(label string, f func() error) error {
+	if p == nil {
+		return f()
+	}
+
+	e := &ProfileEntry{Label: label}
+	fail := func(err error) {
+		if e.Error == "" {
+			e.Error = err.Error()
+		}
+	}
+
+	if p.Dir != "" {
+		if err := os.MkdirAll(p.Dir, 0755); err != nil {
+			fail(err)
+		}
+	}
+
+	// heap profile is cumulative from the program start,
+	// so the profile before the test is subtracted from the one after
+	var heapBase *profile.Profile
+	if p.Heap {
+		var err error
+		if heapBase, err = heapProfile(); err != nil {
+			fail(err)
+		}
+	}
+
+	var cpu bytes.Buffer
+	cpuStarted := false
+	if p.CPU {
+		if err := pprof.StartCPUProfile(&cpu); err != nil {
+			fail(err)
+		} else {
+			cpuStarted = true
+		}
+	}
+
+	err := f()
+
+	if cpuStarted {
+		pprof.StopCPUProfile()
+		fn := p.path(label, "cpu")
+		if err := ioutil.WriteFile(fn, cpu.Bytes(), 0644); err != nil {
+			fail(err)
+		} else {
+			e.CPU = fn
+		}
+		if p.Top > 0 {
+			if rpt, err := ppftool.Top(cpu.Bytes(), &ppftool.Options{Count: p.Top, Hide: p.Hide}); err != nil {
+				fail(err)
+			} else {
+				e.Top = rpt.Rows
+			}
+		}
+	}
+
+	if heapBase != nil {
+		fn := p.path(label, "heap")
+		if heap, err := heapDiff(heapBase); err != nil {
+			fail(err)
+		} else if err := ioutil.WriteFile(fn, heap, 0644); err != nil {
+			fail(err)
+		} else {
+			e.Heap = fn
+		}
+	}
+
+	p.mu.Lock()
+	p.entries = append(p.entries, e)
+	p.mu.Unlock()
+
+	return err
+}
+
+func (p *Profile) capture(group bool, label string, f func() error) error {
+	if p == nil || p.Group != group {
+		return f()
+	}
+	return p.Capture(label, f)
+}
+
+func (p *Profile) Entries() []*ProfileEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*ProfileEntry(nil), p.entries...)
+}
+
+// Merge attaches profile entries to entries of the benchmark results file
+// with the same label
+func (p *Profile) Merge(fn string) error {
+	entries := make(map[string]*ProfileEntry)
+	for _, e := range p.Entries() {
+		entries[e.Label] = e
+	}
+	return mergeResult(fn, func(label string, entry map[string]interface{}) {
+		if e, ok := entries[label]; ok {
+			entry["profile"] = e
+		}
+	})
+}
+
+func heapProfile() (*profile.Profile, error) {
+	// the heap profile is updated by GC
+	runtime.GC()
+	var b bytes.Buffer
+	if err := pprof.WriteHeapProfile(&b); err != nil {
+		return nil, err
+	}
+	return profile.Parse(&b)
+}
+
+// heapDiff returns the current heap profile without samples of the base
+func heapDiff(base *profile.Profile) ([]byte, error) {
+	heap, err := heapProfile()
+	if err != nil {
+		return nil, err
+	}
+	base.Scale(-1)
+	diff, err := profile.Merge([]*profile.Profile{heap, base})
+	if err != nil {
+		return nil, err
+	}
+	samples := diff.Sample[:0]
+	for _, s := range diff.Sample {
+		for _, v := range s.Value {
+			if v != 0 {
+				samples = append(samples, s)
+				break
+			}
+		}
+	}
+	diff.Sample = samples
+	var b bytes.Buffer
+	if err := diff.Write(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
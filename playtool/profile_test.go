@@ -0,0 +1,73 @@
+package playtool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+var profileSink [][]byte
+
+func profileBytes(t *testing.T, fn string, funcName string) int64 {
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	for _, s := range p.Sample {
+		for _, l := range s.Location {
+			for _, line := range l.Line {
+				if strings.HasSuffix(line.Function.Name, funcName) {
+					n += s.Value[1] // alloc_space
+				}
+			}
+		}
+	}
+	return n
+}
+
+func allocBefore() { profileSink = append(profileSink, make([]byte, 8<<20)) }
+func allocInside() { profileSink = append(profileSink, make([]byte, 4<<20)) }
+
+func TestProfileHeap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "playtool-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &Profile{Dir: dir, Heap: true}
+	allocBefore()
+	if err := p.Capture("G/a", func() error { allocInside(); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	e := p.Entries()[0]
+	if e.Error != "" || e.Heap != filepath.Join(dir, "G_a.heap.pprof") {
+		t.Fatalf("wrong entry %+v", e)
+	}
+	if n := profileBytes(t, e.Heap, ".allocBefore"); n != 0 {
+		t.Errorf("allocations before the test are in profile: %d", n)
+	}
+	if n := profileBytes(t, e.Heap, ".allocInside"); n < 4<<20 {
+		t.Errorf("allocations of the test are not in profile: %d", n)
+	}
+
+	fn := filepath.Join(dir, "benchmark.js")
+	ioutil.WriteFile(fn, []byte(`{"label": ".", "children": [{"label": "G/a"}, {"label": "G/b"}]}`), 0644)
+	if err := p.Merge(fn); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(fn)
+	if strings.Count(string(b), `"profile"`) != 1 || !strings.Contains(string(b), `"heap": `) {
+		t.Errorf("profile is not attached\n%s", b)
+	}
+}
@@ -0,0 +1,84 @@
+package playtool
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Samples collects time of every Repeat of the test, benchmark.js written by
+// T.WriteJsonResult has only the total time of the test, so samples are merged
+// into it afterwards and benchmarks/compare can estimate significance
+type Samples struct {
+	mu    sync.Mutex
+	tests map[string][]int64
+}
+
+// Add appends the sample of the test, it's safe to call it on nil
+func (s *Samples) Add(name string, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.tests == nil {
+		s.tests = make(map[string][]int64)
+	}
+	s.tests[name] = append(s.tests[name], int64(d))
+	s.mu.Unlock()
+}
+
+// Merge adds samples to entries of the benchmark results file
+func (s *Samples) Merge(fn string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return mergeResult(fn, func(label string, entry map[string]interface{}) {
+		if samples, ok := s.tests[label]; ok {
+			entry["samples"] = samples
+		}
+	})
+}
+
+// ResultFile returns the file name passed to the benchmark by --result
+func ResultFile() string {
+	if f := flag.Lookup("result"); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// mergeResult calls f for every entry of the benchmark results tree and writes
+// the tree back, fields unknown here are kept as they are
+func mergeResult(fn string, f func(string, map[string]interface{})) error {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(b, &root); err != nil {
+		return fmt.Errorf("malformed benchmark result %s: %v", fn, err)
+	}
+	tree := root
+	if r, ok := root["results"].(map[string]interface{}); ok {
+		tree = r
+	}
+	var walk func(map[string]interface{})
+	walk = func(entry map[string]interface{}) {
+		if label, ok := entry["label"].(string); ok {
+			f(label, entry)
+		}
+		children, _ := entry["children"].([]interface{})
+		for _, c := range children {
+			if c, ok := c.(map[string]interface{}); ok {
+				walk(c)
+			}
+		}
+	}
+	walk(tree)
+	if b, err = json.MarshalIndent(root, "", "  "); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, b, 0644)
+}
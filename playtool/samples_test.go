@@ -0,0 +1,51 @@
+package playtool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sudachen/playground/playtool/compare"
+)
+
+func TestSamplesMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "playtool-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "benchmark.js")
+	ioutil.WriteFile(fn, []byte(`{"results": {"label": ".", "total": 60, "active": 60, "count": 0, "error": null,
+		"children": [{"label": "G", "total": 60, "active": 60, "count": 0, "error": null, "children": [
+			{"label": "G/a", "total": 30, "active": 30, "count": 2, "error": null},
+			{"label": "G/b", "total": 30, "active": 30, "count": 1, "error": null}]}]},
+		"version": 1}`), 0644)
+
+	s := &Samples{}
+	s.Add("G/a", 10)
+	s.Add("G/a", 20)
+	if err := s.Merge(fn); err != nil {
+		t.Fatal(err)
+	}
+	r, err := compare.LoadResult(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := r.Children[0].Children[0], r.Children[0].Children[1]
+	if !reflect.DeepEqual(a.Samples, []int64{10, 20}) || a.Count != 2 {
+		t.Errorf("wrong merged entry %+v", a)
+	}
+	if b.Samples != nil || b.Count != 1 {
+		t.Errorf("wrong entry without samples %+v", b)
+	}
+	if d, _ := ioutil.ReadFile(fn); !reflect.DeepEqual(d[len(d)-16:], []byte(`  "version": 1
+}`)) {
+		t.Errorf("unknown field is lost\n%s", d)
+	}
+
+	// nil samples are not collected
+	var none *Samples
+	none.Add("G/a", 10)
+}
@@ -0,0 +1,241 @@
+package playtool
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+)
+
+// lineReader remembers where lines start while the data passes through it,
+// so the JSON error offset can be turned into the line and column without
+// rescanning of the file
+type lineReader struct {
+	r       io.Reader
+	offset  int64
+	lines   []int64 // starts of the lines which are not forgotten yet
+	base    int64   // start of the last forgotten line
+	dropped int     // count of forgotten lines
+}
+
+func (lr *lineReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			lr.lines = append(lr.lines, lr.offset+int64(i)+1)
+		}
+	}
+	lr.offset += int64(n)
+	return n, err
+}
+
+// forget drops the lines started before offset, it's called when decoder
+// will never report position before it
+func (lr *lineReader) forget(offset int64) {
+	i := sort.Search(len(lr.lines), func(i int) bool { return lr.lines[i] > offset })
+	if i > 0 {
+		lr.base = lr.lines[i-1]
+		lr.dropped += i
+		lr.lines = append(lr.lines[:0], lr.lines[i:]...)
+	}
+}
+
+func (lr *lineReader) position(offset int64) (line, column int) {
+	i := sort.Search(len(lr.lines), func(i int) bool { return lr.lines[i] > offset })
+	start := lr.base
+	if i > 0 {
+		start = lr.lines[i-1]
+	}
+	return lr.dropped + i + 1, int(offset-start) + 1
+}
+
+// error adds position to the syntax error, offset reported by json.Decoder
+// is counted from the value start if it's known
+func (lr *lineReader) error(err error, valueOffset int64) error {
+	if syntaxerr, ok := err.(*json.SyntaxError); ok {
+		offset := syntaxerr.Offset
+		if valueOffset >= 0 {
+			offset = valueOffset + offset - 1
+		}
+		line, column := lr.position(offset)
+		return fmt.Errorf("JSON syntax error at line %v column %v: %v", line, column, err)
+	}
+	return err
+}
+
+// TestStream reads the fixture file which is one big JSON object
+// of named tests, test by test without reading the whole file into memory
+type TestStream struct {
+	File string
+
+	f   *os.File
+	lr  *lineReader
+	dec *json.Decoder
+}
+
+func OpenTestStream(fn string) (*TestStream, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	lr := &lineReader{r: f}
+	s := &TestStream{fn, f, lr, json.NewDecoder(lr)}
+	if tok, err := s.dec.Token(); err != nil {
+		f.Close()
+		return nil, s.error(err, -1)
+	} else if tok != json.Delim('{') {
+		f.Close()
+		return nil, s.error(fmt.Errorf("tests set is not an object"), -1)
+	}
+	return s, nil
+}
+
+func (s *TestStream) Close() error {
+	return s.f.Close()
+}
+
+func (s *TestStream) error(err error, valueOffset int64) error {
+	return fmt.Errorf("%s in file %s", s.lr.error(err, valueOffset).Error(), s.File)
+}
+
+// NextName returns name of the next test or io.EOF if there are no more tests,
+// after that the test value has to be read by Decode or Skip
+func (s *TestStream) NextName() (string, error) {
+	s.lr.forget(s.dec.InputOffset())
+	if !s.dec.More() {
+		return "", io.EOF
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return "", s.error(err, -1)
+	}
+	if name, ok := tok.(string); ok {
+		return name, nil
+	}
+	return "", s.error(fmt.Errorf("test name is expected but %v found", tok), -1)
+}
+
+// rescan reads the failed test value again from the file, because json.Decoder
+// does not report the right offset of syntax error if Token and Decode calls
+// are mixed
+func (s *TestStream) rescan(err error, nameOffset int64) error {
+	f, e := os.Open(s.File)
+	if e != nil {
+		return s.error(err, -1)
+	}
+	defer f.Close()
+	if _, e = f.Seek(nameOffset, io.SeekStart); e != nil {
+		return s.error(err, -1)
+	}
+	r := bufio.NewReader(f)
+	valueOffset := nameOffset
+	for {
+		c, e := r.ReadByte()
+		if e != nil {
+			return s.error(err, -1)
+		}
+		valueOffset++
+		if c == ':' {
+			break
+		}
+	}
+	var raw json.RawMessage
+	if e = json.NewDecoder(r).Decode(&raw); e != nil {
+		return s.error(e, valueOffset)
+	}
+	return s.error(err, -1)
+}
+
+func (s *TestStream) Decode(v interface{}) error {
+	offset := s.dec.InputOffset()
+	if err := s.dec.Decode(v); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return s.rescan(err, offset)
+		}
+		return err
+	}
+	return nil
+}
+
+// Skip reads the test value token by token, so the value is not decoded
+// and not kept in the decoder buffer
+func (s *TestStream) Skip() error {
+	offset := s.dec.InputOffset()
+	depth := 0
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				return s.rescan(err, offset)
+			}
+			return s.error(err, -1)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			if tok != json.Delim('}') {
+				return s.error(fmt.Errorf("test is not an object"), -1)
+			}
+			return nil
+		}
+	}
+}
+
+// TestEntry is the test name and offset of the value which follows it
+type TestEntry struct {
+	Name   string
+	Offset int64
+}
+
+// Index reads names of all remaining tests skipping their values,
+// tests can be decoded later in any order by DecodeAt
+func (s *TestStream) Index() ([]TestEntry, error) {
+	var tests []TestEntry
+	for {
+		name, err := s.NextName()
+		if err == io.EOF {
+			return tests, nil
+		} else if err != nil {
+			return nil, err
+		}
+		tests = append(tests, TestEntry{name, s.dec.InputOffset()})
+		if err := s.Skip(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// DecodeAt decodes the test value found by Index
+func (s *TestStream) DecodeAt(e TestEntry, v interface{}) error {
+	r := bufio.NewReader(io.NewSectionReader(s.f, e.Offset, math.MaxInt64-e.Offset))
+	valueOffset := e.Offset
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return s.error(err, -1)
+		}
+		valueOffset++
+		if c == ':' {
+			break
+		}
+	}
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			// lines are counted again from the file start,
+			// the stream forgot them
+			lr := &lineReader{r: io.NewSectionReader(s.f, 0, math.MaxInt64)}
+			io.Copy(ioutil.Discard, lr)
+			return fmt.Errorf("%s in file %s", lr.error(err, valueOffset).Error(), s.File)
+		}
+		return err
+	}
+	return nil
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"sort"
 	"testing"
@@ -26,36 +25,56 @@ type Nfo struct {
 	Rules  *libeth.RuleSet
 }
 
-func (nfo *Nfo) runAll(rootDir string,f func(string,map[string]interface{})error) error {
+// runAll runs tests in sorted order, tests before SkipTo and skipped ones
+// are not decoded, the file is indexed first and then tests are read one by one
+func (nfo *Nfo) runAll(rootDir string,f func(string,*StateTest)error) error {
 	skipNames := make(map[string]bool)
 	for _, x := range nfo.Skip {
 		skipNames[x] = true
 	}
 	path := filepath.Join(rootDir, nfo.File)
-	var tests map[string]interface{}
-	if err := ReadJsonFile(path, &tests); err != nil {
+	s, err := OpenTestStream(path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	tests, err := s.Index()
+	if err != nil {
 		return err
 	}
-	keys := SortedMapKeys(tests)
+	sort.Slice(tests, func(i, j int) bool { return tests[i].Name < tests[j].Name })
 	if nfo.SkipTo != libeth.NulStr {
-		for len(keys) != 0 && keys[0] != nfo.SkipTo {
-			keys = keys[1:]
+		for len(tests) != 0 && tests[0].Name != nfo.SkipTo {
+			tests = tests[1:]
 		}
 	}
-	for _, k := range keys {
-		if !skipNames[k] {
-			oneTest := tests[k].(map[string]interface{})
-			if err := f(nfo.Name+"/"+k,oneTest); err != nil {
-				return err
-			}
+	for _, e := range tests {
+		if skipNames[e.Name] {
+			continue
+		}
+		oneTest, err := nfo.decode(e.Name, func(v interface{}) error { return s.DecodeAt(e, v) })
+		if err != nil {
+			return err
+		}
+		if err := f(nfo.Name+"/"+e.Name,oneTest); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (nfo *Nfo) decode(name string, decode func(interface{}) error) (*StateTest, error) {
+	test := &StateTest{}
+	if err := decode(test); err != nil {
+		return nil, fmt.Errorf("%s/%s: %v", nfo.Name, name, err)
+	}
+	return test, nil
+}
+
 func (nfo *Nfo) RunAll(tfo *Tfo, t *testing.T) {
-	nfo.runAll(tfo.RootDir,func(name string,test map[string]interface{})error{
+	nfo.runAll(tfo.RootDir,func(name string,test *StateTest)error{
 		if err := tfo.Proc(test, name, nfo.Rules, tfo.NewVM(), t); err != nil {
 			t.Error(err)
 			return err
@@ -64,28 +83,37 @@ func (nfo *Nfo) RunAll(tfo *Tfo, t *testing.T) {
 	})
 }
 
-func (nfo *Nfo) runOne(rootDir string,name string,f func(string,map[string]interface{})error) error {
+func (nfo *Nfo) runOne(rootDir string,name string,f func(string,*StateTest)error) error {
 	path := filepath.Join(rootDir, nfo.File)
-
-	var tests map[string]interface{}
-	if err := ReadJsonFile(path, &tests); err != nil {
+	s, err := OpenTestStream(path)
+	if err != nil {
 		return err
 	}
+	defer s.Close()
 
-	if m,ok := tests[name]; ok {
-		oneTest := m.(map[string]interface{})
-		if err := f(name,oneTest); err != nil {
+	for {
+		k, err := s.NextName()
+		if err == io.EOF {
+			return fmt.Errorf("test %s/%s does not exist",nfo.Name,name)
+		} else if err != nil {
 			return err
 		}
-	} else {
-		return fmt.Errorf("test %s/%s does not exist",nfo.Name,name)
+		if k != name {
+			if err := s.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		oneTest, err := nfo.decode(k, s.Decode)
+		if err != nil {
+			return err
+		}
+		return f(name,oneTest)
 	}
-
-	return nil
 }
 
 func (nfo *Nfo) RunOne(tfo *Tfo, name string, t *testing.T) {
-	nfo.runOne(tfo.RootDir,name,func(name string,test map[string]interface{})error {
+	nfo.runOne(tfo.RootDir,name,func(name string,test *StateTest)error {
 		if err := tfo.Proc(test, name, nfo.Rules, tfo.NewVM(), t); err != nil {
 			t.Error(err)
 			return err
@@ -94,14 +122,16 @@ func (nfo *Nfo) RunOne(tfo *Tfo, name string, t *testing.T) {
 	})
 }
 
-func (nfo *Nfo) getRunnbale(bfo *Bfo,t *benchmark.T) func(name string,test map[string]interface{})error {
-	return func(name string,test map[string]interface{})error {
+func (nfo *Nfo) getRunnbale(bfo *Bfo,t *benchmark.T) func(name string,test *StateTest)error {
+	return func(name string,test *StateTest)error {
 		return t.Run(name,func(t0 *benchmark.T)error {
-			if err := bfo.Proc(bfo.Repeat,test, name, nfo.Rules, bfo.NewVM(), t0); err != nil {
-				t.Error(err)
-				return err
-			}
-			return nil
+			return bfo.Profile.capture(false, name, func() error {
+				if err := bfo.Proc(bfo,test, name, nfo.Rules, bfo.NewVM(), t0); err != nil {
+					t.Error(err)
+					return err
+				}
+				return nil
+			})
 		})
 	}
 }
@@ -162,38 +192,9 @@ func ReadJsonFile(fn string, value interface{}) error {
 }
 
 func readJson(reader io.Reader, value interface{}) error {
-	data, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("error reading JSON file: %v", err)
-	}
-	if err = json.Unmarshal(data, &value); err != nil {
-		if syntaxerr, ok := err.(*json.SyntaxError); ok {
-			line := findLine(data, syntaxerr.Offset)
-			return fmt.Errorf("JSON syntax error at line %v: %v", line, err)
-		}
-		return err
+	lr := &lineReader{r: reader}
+	if err := json.NewDecoder(lr).Decode(value); err != nil {
+		return lr.error(err, 0)
 	}
 	return nil
 }
-
-func findLine(data []byte, offset int64) (line int) {
-	line = 1
-	for i, r := range string(data) {
-		if int64(i) >= offset {
-			return
-		}
-		if r == '\n' {
-			line++
-		}
-	}
-	return
-}
-
-func SortedMapKeys(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	return keys
-}
@@ -0,0 +1,147 @@
+package playtool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testStateTest = `{
+		"env" : {
+			"currentCoinbase" : "2adc25665018aa1fe0e6bc666dac8fc2697ff9ba",
+			"currentDifficulty" : "0x020000",
+			"currentGasLimit" : "0x7fffffffffffffff",
+			"currentNumber" : "0x01",
+			"currentTimestamp" : "0x03e8",
+			"previousHash" : "5e20a0453cecd065ea59c37ac63e079ee08998b6045136a8ce6635c7912ec0b6"
+		},
+		"out" : "0x",
+		"post" : {},
+		"pre" : {},
+		"transaction" : ` + testStateTx + `
+	}`
+
+func writeTestFile(t *testing.T, names ...string) (string, func()) {
+	dir, err := ioutil.TempDir("", "playtool-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := make([]string, len(names))
+	for i, n := range names {
+		tests[i] = "\t\"" + n + "\" : " + testStateTest
+	}
+	data := "{\n" + strings.Join(tests, ",\n") + "\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "tests.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func runNames(t *testing.T, dir string, nfo *Nfo) []string {
+	var names []string
+	err := nfo.runAll(dir, func(name string, test *StateTest) error {
+		if test.Env.Number.Int64() != 1 {
+			t.Errorf("%s is not decoded", name)
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestRunAllOrder(t *testing.T) {
+	dir, remove := writeTestFile(t, "mul", "add", "sub", "div", "exp")
+	defer remove()
+
+	for _, c := range []struct {
+		skipTo string
+		skip   []string
+		names  []string
+	}{
+		{"", nil, []string{"add", "div", "exp", "mul", "sub"}},
+		{"exp", nil, []string{"exp", "mul", "sub"}},
+		{"", []string{"div", "mul"}, []string{"add", "exp", "sub"}},
+		{"div", []string{"mul"}, []string{"div", "exp", "sub"}},
+		{"none", nil, nil},
+	} {
+		nfo := &Nfo{Name: "G", File: "tests.json", SkipTo: c.skipTo, Skip: c.skip}
+		names := runNames(t, dir, nfo)
+		want := make([]string, len(c.names))
+		for i, n := range c.names {
+			want[i] = "G/" + n
+		}
+		if len(names) != 0 || len(want) != 0 {
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("skipTo %q skip %v: have %v, want %v", c.skipTo, c.skip, names, want)
+			}
+		}
+	}
+}
+
+func TestRunOne(t *testing.T) {
+	dir, remove := writeTestFile(t, "mul", "add", "sub")
+	defer remove()
+
+	nfo := &Nfo{Name: "G", File: "tests.json"}
+	found := ""
+	if err := nfo.runOne(dir, "add", func(name string, _ *StateTest) error {
+		found = name
+		return nil
+	}); err != nil || found != "add" {
+		t.Errorf("test is not found: %q %v", found, err)
+	}
+	if err := nfo.runOne(dir, "div", func(string, *StateTest) error { return nil }); err == nil {
+		t.Error("absent test is found")
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "playtool-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "tests.json")
+
+	for _, c := range []struct {
+		data, err string
+	}{
+		{"{\n\"a\" : {\"x\" : [1, 2, {}]},\n\"b\" : [1]\n}", "test is not an object"},
+		{"{\n\"a\" : {\"x\" : [1, 2, {}]},\n\"b\" : \"s\"\n}", "test is not an object"},
+		{"{\n\"a\" : {\"x\" : [1, 2, {}]},\n\"b\" : {\"x\" : 1,,}\n}", "line 3 column"},
+	} {
+		ioutil.WriteFile(fn, []byte(c.data), 0644)
+		s, err := OpenTestStream(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Index()
+		s.Close()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: wrong error %v", c.data, err)
+		}
+	}
+
+	// the whole file is checked before any test is run
+	ioutil.WriteFile(fn, []byte("{\n\"a\" : {\"env\" : {}},\n\"b\" : {\"env\" :\n {\"x\" : 0x1}}\n}"), 0644)
+	nfo := &Nfo{Name: "G", File: "tests.json"}
+	run := 0
+	err = nfo.runAll(dir, func(string, *StateTest) error { run++; return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 4 column") || run != 0 {
+		t.Errorf("wrong error %v", err)
+	}
+
+	// the fixture error is reported with the test name
+	ioutil.WriteFile(fn, []byte("{\n\"b\" : {\"env\" : []},\n\"a\" : {\"env\" : {}}\n}"), 0644)
+	nfo.Skip = []string{"a"}
+	err = nfo.runAll(dir, func(string, *StateTest) error { return nil })
+	if err == nil || !strings.HasPrefix(err.Error(), "G/b:") {
+		t.Errorf("wrong error %v", err)
+	}
+}
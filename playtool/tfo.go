@@ -15,7 +15,7 @@ import (
 )
 
 type Tfo struct {
-	Proc    func(map[string]interface{},string,*libeth.RuleSet,libeth.VM,*testing.T)error
+	Proc    func(*StateTest,string,*libeth.RuleSet,libeth.VM,*testing.T)error
 	NewVM   func() libeth.VM
 	RootDir string
 }